@@ -69,13 +69,40 @@ func main() {
 		return minTime, bytesCount, nil
 	}
 
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		_ = watcher.Close()
+	}()
+
 	updateRenderer := func() {
 		mx.Lock()
 		defer mx.Unlock()
 
 		info = ""
 		renderer, rendererErr = decorender.NewRenderer(layoutFileName, nil)
+
+		// Included files may change with every update of layout, so keep watching all of them,
+		// including broken ones, so fixing them reloads renderer
+		var includedFiles []string
 		if rendererErr == nil {
+			includedFiles = renderer.IncludedFiles()
+		} else {
+			includedFiles, _ = decorender.LoadIncludedFiles(layoutFileName, nil)
+		}
+		for _, fileName := range includedFiles {
+			if err := watcher.Add(fileName); err != nil {
+				log.Printf("Failed to watch included file %v: %v", fileName, err)
+			}
+		}
+
+		if rendererErr == nil {
+			for _, w := range renderer.Warnings() {
+				log.Printf("Warning: %v", w)
+			}
+
 			var timeRender time.Duration
 			var timeWithPNGEncode time.Duration
 			var timeWithJGPEncode time.Duration
@@ -101,8 +128,12 @@ func main() {
 		}
 	}
 
+	if err = watcher.Add(layoutFileName); err != nil {
+		log.Fatal(err)
+	}
+
 	go func() {
-		watchFile(layoutFileName, func() {
+		watchFiles(watcher, func() {
 			updateRenderer()
 		})
 	}()
@@ -146,7 +177,7 @@ func main() {
 	}()
 
 	log.Println("Decorender dev server is running at http://localhost:8089")
-	err := http.ListenAndServe(":8089", nil)
+	err = http.ListenAndServe(":8089", nil)
 	if err != nil {
 		log.Fatalf("Failed to start decorender server: %v", err)
 	}
@@ -167,39 +198,22 @@ func openBrowser(url string) error {
 	return err
 }
 
-func watchFile(filePath string, action func()) {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer func() {
-		_ = watcher.Close()
-	}()
-
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					action()
-				}
-			case _, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
+func watchFiles(watcher *fsnotify.Watcher, action func()) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&fsnotify.Write == fsnotify.Write {
+				action()
+			}
+		case _, ok := <-watcher.Errors:
+			if !ok {
+				return
 			}
 		}
-	}()
-
-	err = watcher.Add(filePath)
-	if err != nil {
-		log.Fatal(err)
 	}
-	<-done
 }
 
 type CountingWriter struct {
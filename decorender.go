@@ -10,22 +10,33 @@ import (
 	"io/fs"
 	"math"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
 
+	"github.com/bluele/gcache"
 	"github.com/godknowsiamgood/decorender/internal/fonts"
+	"github.com/godknowsiamgood/decorender/internal/formatting"
 	"github.com/godknowsiamgood/decorender/internal/layout"
 	"github.com/godknowsiamgood/decorender/internal/parsing"
 	"github.com/godknowsiamgood/decorender/internal/render"
 	resources_internal "github.com/godknowsiamgood/decorender/internal/resources"
+	"github.com/godknowsiamgood/decorender/internal/translations"
 	"github.com/godknowsiamgood/decorender/internal/utils"
 	"github.com/godknowsiamgood/decorender/resources"
 	"github.com/samber/lo"
-	"gopkg.in/yaml.v3"
+	"golang.org/x/exp/slices"
+	"golang.org/x/text/language"
 )
 
 var NothingToRenderErr = errors.New("nothing to render")
 
+// ValidationErrors is returned by NewRenderer when template has invalid fields.
+// Each ValidationError contains file, line and column of the problem.
+type ValidationErrors = parsing.ValidationErrors
+type ValidationError = parsing.ValidationError
+
 type EncodeFormat uint
 
 const (
@@ -41,6 +52,10 @@ type RenderOptions struct {
 	UseSample bool
 	// Quality sets quality for encoding formats that supports quality
 	Quality float64
+	// Locale overrides Options.Locale for this render, e.g. "de-DE"
+	Locale string
+	// Language of translations for this render, e.g. "de". Default is Locale, then Options.Language.
+	Language string
 }
 
 // Options are options for Decorender instance
@@ -55,14 +70,35 @@ type Options struct {
 	// When NoImageCache is true, no decoded and scaled images are kept in memory cache.
 	// Default cache is fixed size LRU.
 	NoImageCache bool
+
+	// DataType is optional type of user data passed to Render.
+	// When it is set, all expressions are type-checked against it at renderer creation.
+	DataType reflect.Type
+
+	// Functions are custom Go functions available in expressions, e.g. "~ formatPrice(Price)".
+	// Each function must return one value and optionally an error.
+	Functions map[string]any
+
+	// Locale is default locale of built-in formatting functions (number, currency, date etc.), e.g. "de-DE".
+	// Default is "en-US".
+	Locale string
+
+	// Language is default language of translations, and is used for keys missing in language of render.
+	// Default is Locale.
+	Language string
 }
 
 type Decorender struct {
-	root          parsing.Node
-	layoutCache   *layout.Cache
+	root     parsing.Node
+	template *layout.Template
+	// templateRoot and compileOpts are kept to compile template for other locales and languages
+	templateRoot  parsing.Node
+	compileOpts   layout.CompileOptions
+	templates     gcache.Cache
 	renderCache   *render.Cache
 	externalImage resources.ExternalImage
 	localFiles    fs.FS
+	includedFiles []string
 }
 
 func NewRenderer(yamlFileName string, opts *Options) (*Decorender, error) {
@@ -70,44 +106,108 @@ func NewRenderer(yamlFileName string, opts *Options) (*Decorender, error) {
 	if err != nil {
 		return nil, err
 	}
-	return NewRendererWithTemplate(content, opts)
+	return newRenderer(yamlFileName, content, opts)
 }
 
 func NewRendererWithTemplate(template []byte, opts *Options) (*Decorender, error) {
-	node := yaml.Node{}
-	err := yaml.Unmarshal(template, &node)
-	if err != nil {
-		return nil, err
+	return newRenderer("", template, opts)
+}
+
+func getLocalFiles(opts *Options) fs.FS {
+	if opts != nil && opts.LocalFiles != nil {
+		return opts.LocalFiles
 	}
+	return os.DirFS(".")
+}
 
-	var root parsing.Node
+func newRenderer(fileName string, template []byte, opts *Options) (*Decorender, error) {
+	localFiles := getLocalFiles(opts)
+
+	root, includedFiles, err := parsing.Load(fileName, template, localFiles)
+	validationErrs, _ := err.(parsing.ValidationErrors)
+	if err != nil && validationErrs == nil {
+		return nil, err
+	}
 
-	err = node.Decode(&root)
+	root, err = parsing.ExpandComponents(root)
 	if err != nil {
+		var expandErr *parsing.ValidationError
+		if !errors.As(err, &expandErr) {
+			return nil, err
+		}
+		// Template is not compiled without components, so other validation errors are returned along
+		return nil, append(validationErrs, expandErr).Normalize()
+	}
+
+	var compileOpts layout.CompileOptions
+	var locale, lang string
+	if opts != nil {
+		compileOpts.DataType = opts.DataType
+		if compileOpts.Functions, err = layout.NewFunctions(opts.Functions); err != nil {
+			return nil, err
+		}
+		locale, lang = opts.Locale, opts.Language
+	}
+	if compileOpts.Locale, err = formatting.ParseLocale(locale); err != nil {
 		return nil, err
 	}
 
-	root = parsing.KeepDebugNodes(root)
+	if len(root.Translations) > 0 {
+		if compileOpts.Translations, err = translations.Load(root.Translations, localFiles); err != nil {
+			return nil, err
+		}
+		includedFiles = withTranslationFiles(includedFiles, root)
+	}
+	compileOpts.Language = compileOpts.Translations.Match(lo.Ternary(lang != "", lang, compileOpts.Locale.String()))
+	compileOpts.FallbackLanguage = compileOpts.Language
+
+	compiled, compileErrs := layout.Compile(root, compileOpts)
+	validationErrs = append(validationErrs, compileErrs...)
+	if len(validationErrs) > 0 {
+		return nil, validationErrs.Normalize()
+	}
+
+	// Template is already checked, so types are not needed anymore
+	compileOpts.DataType = nil
+
+	templateRoot := root
+	if debugRoot, has := parsing.KeepDebugNodes(root); has {
+		// Debug node is moved out of its scope, so types of expressions can't be checked anymore
+		templateRoot = debugRoot
+		if compiled, compileErrs = layout.Compile(templateRoot, compileOpts); len(compileErrs) > 0 {
+			return nil, compileErrs.Normalize()
+		}
+	}
 
 	dr := &Decorender{
-		root: root,
+		root:          root,
+		template:      compiled,
+		templateRoot:  templateRoot,
+		compileOpts:   compileOpts,
+		localFiles:    localFiles,
+		includedFiles: includedFiles,
 	}
 
+	dr.templates = gcache.New(templatesCacheSize).LRU().LoaderFunc(func(k any) (any, error) {
+		key := k.(templateKey)
+		compileOpts := dr.compileOpts
+		compileOpts.Locale = key.locale
+		compileOpts.Language = key.language
+		t, errs := layout.Compile(dr.templateRoot, compileOpts)
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		return t, nil
+	}).Build()
+
 	if opts != nil && opts.ExternalImage != nil {
 		dr.externalImage = opts.ExternalImage
 	} else {
 		dr.externalImage = resources_internal.NewDefaultExternalImage()
 	}
 
-	if opts != nil && opts.LocalFiles != nil {
-		dr.localFiles = opts.LocalFiles
-	} else {
-		dr.localFiles = os.DirFS(".")
-	}
-
 	imagesCacheSize := lo.Ternary(opts != nil && opts.NoImageCache, 0, 30)
 
-	dr.layoutCache = layout.NewCache()
 	dr.renderCache = render.NewCache(dr.externalImage, dr.localFiles, imagesCacheSize)
 
 	if err = fonts.LoadFaces(root.FontFaces, dr.localFiles); err != nil {
@@ -117,6 +217,80 @@ func NewRendererWithTemplate(template []byte, opts *Options) (*Decorender, error
 	return dr, nil
 }
 
+// IncludedFiles returns names of all files included by template.
+// Names are relative to Options.LocalFiles.
+func (r *Decorender) IncludedFiles() []string {
+	return r.includedFiles
+}
+
+// LoadIncludedFiles returns names of files included by template, relative to Options.LocalFiles.
+// Unlike IncludedFiles it doesn't need a renderer, so if template is broken it still returns
+// files that were read before error, together with that error.
+func LoadIncludedFiles(yamlFileName string, opts *Options) ([]string, error) {
+	content, err := os.ReadFile(yamlFileName)
+	if err != nil {
+		return nil, err
+	}
+	root, includedFiles, err := parsing.Load(yamlFileName, content, getLocalFiles(opts))
+	return withTranslationFiles(includedFiles, root), err
+}
+
+// withTranslationFiles adds files of translations catalogs to included files
+func withTranslationFiles(includedFiles []string, root parsing.Node) []string {
+	if len(root.Translations) == 0 {
+		return includedFiles
+	}
+	translationFiles := lo.Map(lo.Values(root.Translations), func(fileName string, _ int) string { return path.Clean(fileName) })
+	slices.Sort(translationFiles)
+	return lo.Uniq(append(includedFiles, translationFiles...))
+}
+
+// templatesCacheSize is how many templates compiled for locales and languages other than default are kept
+const templatesCacheSize = 20
+
+// templateKey identifies template compiled for particular locale and language of translations catalog
+type templateKey struct {
+	locale   language.Tag
+	language string
+}
+
+// getTemplate returns template compiled for locale and language of render.
+// Templates are compiled on first use of locale and language, and recently used ones are kept.
+func (r *Decorender) getTemplate(opts *RenderOptions) (*layout.Template, error) {
+	if opts == nil || (opts.Locale == "" && opts.Language == "") {
+		return r.template, nil
+	}
+
+	key := templateKey{locale: r.compileOpts.Locale, language: r.compileOpts.Language}
+
+	if opts.Locale != "" {
+		var err error
+		if key.locale, err = formatting.ParseLocale(opts.Locale); err != nil {
+			return nil, err
+		}
+	}
+
+	requestedLanguage, _ := lo.Coalesce(opts.Language, opts.Locale)
+	if lang := r.compileOpts.Translations.Match(requestedLanguage); lang != "" {
+		key.language = lang
+	}
+
+	if key.locale == r.compileOpts.Locale && key.language == r.compileOpts.Language {
+		return r.template, nil
+	}
+
+	t, err := r.templates.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return t.(*layout.Template), nil
+}
+
+// Warnings returns problems of template that don't prevent rendering, e.g. missing translations
+func (r *Decorender) Warnings() ValidationErrors {
+	return r.template.Warnings()
+}
+
 func (r *Decorender) RenderAndWrite(userData any, format EncodeFormat, w io.Writer, opts *RenderOptions) error {
 	dst, release, err := r.Render(userData, opts)
 	if err != nil {
@@ -146,7 +320,12 @@ func (r *Decorender) Render(userData any, opts *RenderOptions) (dst image.Image,
 
 	// First phase is layout
 
-	nodes, err := layout.Do(r.root, userData, r.externalImage, r.layoutCache)
+	template, err := r.getTemplate(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nodes, err := layout.Do(template, userData, r.externalImage)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -1,8 +1,16 @@
 package decorender
 
 import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestFull(t *testing.T) {
@@ -21,13 +29,1387 @@ func TestFull(t *testing.T) {
 	}
 }
 
+// benchmarkTemplate is a fixed template of typical card, so numbers of BenchmarkRender are comparable between versions
+const benchmarkTemplate = `
+size: 400 300
+bkgColor: 0xf0f0f0
+padding: 20
+innerGap: 10
+inner:
+  - innerDirection: row
+    innerGap: 10
+    inner:
+      - size: 60 60
+        bkgColor: 0x3366cc
+        borderRadius: 30
+      - width: 280
+        inner:
+          - text: ~ Title
+            font: 24 700
+          - text: ~ Subtitle
+            color: 0x666666
+  - innerDirection: row
+    innerGap: 10
+    inner:
+      - forEach: Tags
+        padding: 4 8
+        bkgColor: 0xdddddd
+        borderRadius: 4
+        border: 1 0x999999
+        text: ~ value
+  - forEach: Lines
+    innerDirection: row
+    inner:
+      - width: 200
+        text: ~ value.Name
+      - text: ~ value.Price
+        color: 0xcc3333
+sample:
+  Title: Weekly report
+  Subtitle: Sales of the last week by every product in all regions of the country
+  Tags: [new, sale, popular, limited]
+  Lines:
+    - {Name: Apples, Price: "$12.50"}
+    - {Name: Oranges, Price: "$8.20"}
+    - {Name: Bananas, Price: "$4.10"}
+    - {Name: Pears, Price: "$9.90"}
+`
+
 func BenchmarkRender(b *testing.B) {
-	d, _ := NewRenderer("./cmd/decorender_server/bilets/bilets.yaml", &Options{
-		LocalFiles: os.DirFS("cmd/decorender_server"),
-	})
+	d, err := NewRendererWithTemplate([]byte(benchmarkTemplate), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
 			_ = d.RenderAndWrite(nil, EncodeFormatJPG, nil, &RenderOptions{UseSample: true})
 		}
 	})
 }
+
+// renderTemplate renders template without data, image is released when test ends
+func renderTemplate(t *testing.T, template string) image.Image {
+	t.Helper()
+
+	d, err := NewRendererWithTemplate([]byte(template), nil)
+	if err != nil {
+		t.Fatalf("unexpected error while yaml parse: %v", err)
+	}
+
+	img, release, err := d.Render(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error while rendering: %v", err)
+	}
+	t.Cleanup(release)
+
+	return img
+}
+
+// pixel is expected color of image at x, y
+type pixel struct {
+	x, y int
+	c    color.RGBA
+}
+
+// assertPixels checks colors of image at every pixel
+func assertPixels(t *testing.T, img image.Image, pixels []pixel) {
+	t.Helper()
+	for _, p := range pixels {
+		if img.At(p.x, p.y) != p.c {
+			t.Errorf("unexpected color %v at %v %v, expected %v", img.At(p.x, p.y), p.x, p.y, p.c)
+		}
+	}
+}
+
+// pixelTest is a template with expected colors of some pixels, and expected size of image if it is set
+type pixelTest struct {
+	name     string
+	template string
+	size     [2]int
+	expected []pixel
+}
+
+// runPixelTests renders template of every test without data and checks its size and pixels
+func runPixelTests(t *testing.T, tests []pixelTest) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := renderTemplate(t, tt.template)
+
+			if size := [2]int{img.Bounds().Dx(), img.Bounds().Dy()}; tt.size != [2]int{} && size != tt.size {
+				t.Errorf("unexpected size %v, expected %v", size, tt.size)
+			}
+			assertPixels(t, img, tt.expected)
+		})
+	}
+}
+
+func TestConditions(t *testing.T) {
+	d, err := NewRendererWithTemplate([]byte(`
+size: 2 1
+innerDirection: row
+inner:
+  - forEach: Items
+    if: ~ value > 1
+    size: 1 1
+    bkgColor: red
+  - else: true
+    size: 1 1
+    bkgColor: blue
+`), nil)
+	if err != nil {
+		t.Fatalf("unexpected error while yaml parse: %v", err)
+	}
+
+	tests := []struct {
+		items    []int
+		expected []color.RGBA
+	}{
+		{items: []int{2, 0}, expected: []color.RGBA{{R: 255, A: 255}, {}}},
+		{items: []int{0, 1}, expected: []color.RGBA{{B: 255, A: 255}, {}}},
+		{items: []int{}, expected: []color.RGBA{{B: 255, A: 255}, {}}},
+		{items: []int{2, 3}, expected: []color.RGBA{{R: 255, A: 255}, {R: 255, A: 255}}},
+	}
+
+	for _, tt := range tests {
+		img, release, err := d.Render(map[string]any{"Items": tt.items}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error while rendering: %v", err)
+		}
+		for x, c := range tt.expected {
+			if img.At(x, 0) != c {
+				t.Errorf("items %v: unexpected color %v at %v, expected %v", tt.items, img.At(x, 0), x, c)
+			}
+		}
+		release()
+	}
+}
+
+func TestForEachMapKeys(t *testing.T) {
+	// Any map key can be iterated, not only identifiers
+	d, err := NewRendererWithTemplate([]byte(`
+size: 3 1
+innerDirection: row
+inner:
+  - forEach: line-items
+    size: 1 1
+    bkgColor: red
+  - forEach: ~ Key
+    size: 1 1
+    bkgColor: blue
+`), nil)
+	if err != nil {
+		t.Fatalf("unexpected error while yaml parse: %v", err)
+	}
+
+	img, release, err := d.Render(map[string]any{"line-items": []int{1, 2}, "Key": "more items", "more items": []int{1}}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error while rendering: %v", err)
+	}
+	defer release()
+
+	expected := []color.RGBA{{R: 255, A: 255}, {R: 255, A: 255}, {B: 255, A: 255}}
+	for x, c := range expected {
+		if img.At(x, 0) != c {
+			t.Errorf("unexpected color %v at %v, expected %v", img.At(x, 0), x, c)
+		}
+	}
+}
+
+func TestIndexInNestedNodes(t *testing.T) {
+	d, err := NewRendererWithTemplate([]byte(`
+size: 2 1
+innerDirection: row
+inner:
+  - forEach: 2
+    size: 1 1
+    inner:
+      - size: 1 1
+        bkgColor: "~ index == 1 ? 'red' : 'blue'"
+`), nil)
+	if err != nil {
+		t.Fatalf("unexpected error while yaml parse: %v", err)
+	}
+
+	img, release, err := d.Render(map[string]any{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error while rendering: %v", err)
+	}
+	defer release()
+
+	expected := []color.RGBA{{B: 255, A: 255}, {R: 255, A: 255}}
+	for x, c := range expected {
+		if img.At(x, 0) != c {
+			t.Errorf("unexpected color %v at %v, expected %v", img.At(x, 0), x, c)
+		}
+	}
+}
+
+func TestGrowAndShrink(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		expected []color.RGBA
+	}{
+		{
+			name: "grow in row",
+			template: `
+size: 4 1
+innerDirection: row
+inner:
+  - size: 1 1
+    bkgColor: red
+  - height: 1
+    grow: 1
+    bkgColor: blue`,
+			expected: []color.RGBA{{R: 255, A: 255}, {B: 255, A: 255}, {B: 255, A: 255}, {B: 255, A: 255}},
+		},
+		{
+			name: "content of grown node is laid out again",
+			template: `
+size: 4 1
+innerDirection: row
+inner:
+  - size: 1 1
+    bkgColor: red
+  - grow: 1
+    innerDirection: row
+    justify: end
+    inner:
+      - size: 1 1
+        bkgColor: blue`,
+			expected: []color.RGBA{{R: 255, A: 255}, {}, {}, {B: 255, A: 255}},
+		},
+		{
+			name: "shrink in row",
+			template: `
+size: 4 1
+innerDirection: row
+innerWrap: none
+inner:
+  - size: 3 1
+    shrink: 1
+    bkgColor: red
+  - size: 3 1
+    shrink: 1
+    bkgColor: blue`,
+			expected: []color.RGBA{{R: 255, A: 255}, {R: 255, A: 255}, {B: 255, A: 255}, {B: 255, A: 255}},
+		},
+		{
+			name: "grow in column",
+			template: `
+size: 1 4
+inner:
+  - size: 1 1
+    bkgColor: red
+  - width: 1
+    grow: 1
+    bkgColor: blue
+  - width: 1
+    grow: 2
+    bkgColor: lime`,
+			expected: []color.RGBA{{R: 255, A: 255}, {B: 255, A: 255}, {G: 255, A: 255}, {G: 255, A: 255}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := renderTemplate(t, tt.template)
+
+			isVertical := img.Bounds().Dy() > 1
+			for i, c := range tt.expected {
+				x, y := i, 0
+				if isVertical {
+					x, y = 0, i
+				}
+				if img.At(x, y) != c {
+					t.Errorf("unexpected color %v at %v, expected %v", img.At(x, y), i, c)
+				}
+			}
+		})
+	}
+}
+
+func TestAlign(t *testing.T) {
+	red, blue, green := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}, color.RGBA{G: 128, A: 255}
+
+	tests := []struct {
+		name     string
+		template string
+		height   int
+		expected []pixel
+	}{
+		{
+			name: "center",
+			template: `
+innerDirection: row
+align: center
+inner:
+  - size: 1 3
+    bkgColor: red
+  - size: 1 1
+    bkgColor: blue`,
+			height:   3,
+			expected: []pixel{{1, 0, color.RGBA{}}, {1, 1, blue}, {1, 2, color.RGBA{}}},
+		},
+		{
+			name: "end",
+			template: `
+innerDirection: row
+align: end
+inner:
+  - size: 1 3
+    bkgColor: red
+  - size: 1 1
+    bkgColor: blue`,
+			height:   3,
+			expected: []pixel{{1, 0, color.RGBA{}}, {1, 2, blue}},
+		},
+		{
+			name: "stretch",
+			template: `
+innerDirection: row
+align: stretch
+inner:
+  - size: 1 3
+    bkgColor: red
+  - width: 1
+    bkgColor: blue`,
+			height:   3,
+			expected: []pixel{{0, 0, red}, {1, 0, blue}, {1, 2, blue}},
+		},
+		{
+			name: "stretch to height of parent with single row",
+			template: `
+size: 1 3
+innerDirection: row
+align: stretch
+inner:
+  - width: 1
+    bkgColor: blue`,
+			height:   3,
+			expected: []pixel{{0, 0, blue}, {0, 2, blue}},
+		},
+		{
+			name: "align self",
+			template: `
+innerDirection: row
+align: end
+inner:
+  - size: 1 3
+    bkgColor: red
+  - size: 1 1
+    alignSelf: start
+    bkgColor: blue`,
+			height:   3,
+			expected: []pixel{{1, 0, blue}, {1, 2, color.RGBA{}}},
+		},
+		{
+			name: "baseline",
+			template: `
+innerDirection: row
+align: baseline
+inner:
+  - size: 1 3
+    bkgColor: red
+  - padding: 0 0 1 0
+    bkgColor: green
+    inner:
+      - size: 1 1
+        bkgColor: blue`,
+			height:   4,
+			expected: []pixel{{0, 2, red}, {1, 1, color.RGBA{}}, {1, 2, blue}, {1, 3, green}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := renderTemplate(t, "innerWrap: none"+tt.template)
+
+			if img.Bounds().Dy() != tt.height {
+				t.Errorf("unexpected height %v, expected %v", img.Bounds().Dy(), tt.height)
+			}
+			assertPixels(t, img, tt.expected)
+		})
+	}
+}
+
+func TestGrid(t *testing.T) {
+	red, blue, lime := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}, color.RGBA{G: 255, A: 255}
+
+	runPixelTests(t, []pixelTest{
+		{
+			name: "fixed and fractional columns",
+			template: `
+width: 6
+innerDirection: grid
+gridColumns: 1 1fr 2fr
+gridGap: 0 1
+inner:
+  - height: 1
+    bkgColor: red
+  - height: 1
+    bkgColor: blue
+  - height: 1
+    bkgColor: lime`,
+			size:     [2]int{6, 1},
+			expected: []pixel{{0, 0, red}, {1, 0, color.RGBA{}}, {2, 0, blue}, {3, 0, color.RGBA{}}, {4, 0, lime}, {5, 0, lime}},
+		},
+		{
+			name: "column span",
+			template: `
+innerDirection: grid
+gridColumns: 1 1
+inner:
+  - height: 1
+    columnSpan: 2
+    bkgColor: red
+  - height: 1
+    bkgColor: blue
+  - height: 1
+    bkgColor: lime`,
+			size:     [2]int{2, 2},
+			expected: []pixel{{0, 0, red}, {1, 0, red}, {0, 1, blue}, {1, 1, lime}},
+		},
+		{
+			name: "row span",
+			template: `
+innerDirection: grid
+gridColumns: 1 1
+align: stretch
+inner:
+  - rowSpan: 2
+    bkgColor: red
+  - height: 1
+    bkgColor: blue
+  - height: 1
+    bkgColor: lime`,
+			size:     [2]int{2, 2},
+			expected: []pixel{{0, 0, red}, {0, 1, red}, {1, 0, blue}, {1, 1, lime}},
+		},
+	})
+}
+
+// nestedTemplate returns template with nodes nested to depth, every nested node has leaf sibling.
+// Every node evaluates tick once.
+func nestedTemplate(depth int, props string) string {
+	var b strings.Builder
+	b.WriteString("size: 400 400\nbkgColor: ~ tick()\n" + props)
+	for i := 1; i <= depth; i++ {
+		indent := strings.Repeat("    ", i-1)
+		b.WriteString(indent + "inner:\n")
+		b.WriteString(indent + "  - height: 10\n")
+		b.WriteString(indent + "    bkgColor: ~ tick()\n")
+		b.WriteString(indent + "  - bkgColor: ~ tick()\n")
+		for _, p := range strings.Split(strings.TrimSpace(props), "\n") {
+			b.WriteString(indent + "    " + p + "\n")
+		}
+	}
+	return b.String()
+}
+
+func TestNestingEvaluations(t *testing.T) {
+	// Children laid out again with sizes forced by parent are not evaluated again,
+	// so every node is evaluated once at any depth
+	for _, props := range []string{
+		"innerDirection: grid\ngridColumns: 1fr 1fr\n",
+		"innerDirection: row\nalign: stretch\n",
+		"innerDirection: row\ngrow: 1\n",
+	} {
+		for _, depth := range []int{4, 8, 12} {
+			var count int
+			d, err := NewRendererWithTemplate([]byte(nestedTemplate(depth, props)), &Options{Functions: map[string]any{
+				"tick": func() string {
+					count++
+					return "red"
+				},
+			}})
+			if err != nil {
+				t.Fatalf("unexpected error while yaml parse: %v", err)
+			}
+
+			_, release, err := d.Render(nil, nil)
+			if err != nil {
+				t.Fatalf("unexpected error while rendering: %v", err)
+			}
+			release()
+
+			if count != depth*2+1 {
+				t.Errorf("%vunexpected evaluations at depth %v: %v", props, depth, count)
+			}
+		}
+	}
+}
+
+func BenchmarkNestedGrid(b *testing.B) {
+	d, err := NewRendererWithTemplate([]byte(nestedTemplate(12, "innerDirection: grid\ngridColumns: 1fr 1fr\n")), &Options{
+		Functions: map[string]any{"tick": func() string { return "red" }},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, release, err := d.Render(nil, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		release()
+	}
+}
+
+func TestMargin(t *testing.T) {
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+
+	runPixelTests(t, []pixelTest{
+		{
+			name: "row",
+			template: `
+innerDirection: row
+innerWrap: none
+inner:
+  - size: 1 1
+    margin: 0 1 0 0
+    bkgColor: red
+  - size: 1 1
+    bkgColor: blue`,
+			size:     [2]int{3, 1},
+			expected: []pixel{{0, 0, red}, {1, 0, color.RGBA{}}, {2, 0, blue}},
+		},
+		{
+			name: "column",
+			template: `
+inner:
+  - size: 1 1
+    margin: 1
+    bkgColor: red`,
+			size:     [2]int{3, 3},
+			expected: []pixel{{0, 0, color.RGBA{}}, {1, 1, red}, {2, 2, color.RGBA{}}},
+		},
+		{
+			name: "negative",
+			template: `
+innerDirection: row
+innerWrap: none
+inner:
+  - size: 2 1
+    bkgColor: red
+  - size: 1 1
+    margin: 0 0 0 -1
+    bkgColor: blue`,
+			size:     [2]int{2, 1},
+			expected: []pixel{{0, 0, red}, {1, 0, blue}},
+		},
+		{
+			name: "wrapping",
+			template: `
+width: 3
+innerDirection: row
+inner:
+  - forEach: 2
+    size: 1 1
+    margin: 0 1
+    bkgColor: red`,
+			size:     [2]int{3, 2},
+			expected: []pixel{{1, 0, red}, {1, 1, red}, {0, 1, color.RGBA{}}},
+		},
+	})
+}
+
+func TestOverflow(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+
+	runPixelTests(t, []pixelTest{
+		{
+			name: "visible",
+			template: `
+size: 4 4
+inner:
+  - size: 2 2
+    inner:
+      - size: 4 4
+        bkgColor: red`,
+			expected: []pixel{{1, 1, red}, {3, 3, red}},
+		},
+		{
+			name: "hidden",
+			template: `
+size: 4 4
+inner:
+  - size: 2 2
+    overflow: hidden
+    inner:
+      - size: 4 4
+        bkgColor: red`,
+			expected: []pixel{{1, 1, red}, {2, 2, color.RGBA{}}, {3, 3, color.RGBA{}}},
+		},
+		{
+			name: "hidden with border radius",
+			template: `
+size: 12 12
+inner:
+  - size: 10 10
+    overflow: hidden
+    borderRadius: 5
+    inner:
+      - size: 10 10
+        bkgColor: red`,
+			expected: []pixel{{0, 0, color.RGBA{}}, {5, 5, red}},
+		},
+		{
+			name: "hidden root with border radius",
+			template: `
+size: 10 10
+overflow: hidden
+borderRadius: 5
+inner:
+  - size: 10 10
+    bkgColor: red`,
+			expected: []pixel{{0, 0, color.RGBA{}}, {9, 9, color.RGBA{}}, {5, 5, red}},
+		},
+	})
+}
+
+func TestFitFontSize(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+
+	runPixelTests(t, []pixelTest{
+		{
+			name: "fixed size",
+			template: `
+size: 100 20
+inner:
+  - fontSize: 5
+    color: red
+    bkgColor: red
+    text: Hi`,
+			expected: []pixel{{1, 1, red}, {1, 18, color.RGBA{}}},
+		},
+		{
+			name: "fit to height",
+			template: `
+size: 100 20
+inner:
+  - fontSize: fit
+    color: red
+    bkgColor: red
+    text: Hi`,
+			expected: []pixel{{1, 1, red}, {1, 18, red}},
+		},
+		{
+			name: "max font size",
+			template: `
+size: 100 20
+inner:
+  - fontSize: fit
+    maxFontSize: 5
+    color: red
+    bkgColor: red
+    text: Hi`,
+			expected: []pixel{{1, 1, red}, {1, 18, color.RGBA{}}},
+		},
+	})
+}
+
+func TestTextAlign(t *testing.T) {
+	// Text of three rows, last row is much shorter than the others
+	const template = `
+size: 100 30
+inner:
+  - width: 100
+    font: 10
+    lineHeight: 10
+    textAlign: %v
+    text: aaa bb c dd eee f hh kkk ii l mm nnn o rr sss tt u vv`
+
+	// inkBounds returns horizontal bounds of text in row
+	inkBounds := func(t *testing.T, textAlign string, row int) (left int, right int) {
+		img := renderTemplate(t, fmt.Sprintf(template, textAlign))
+
+		left, right = -1, -1
+		for x := 0; x < 100; x++ {
+			for y := row * 10; y < row*10+10; y++ {
+				if _, _, _, a := img.At(x, y).RGBA(); a > 0 {
+					if left == -1 {
+						left = x
+					}
+					right = x
+				}
+			}
+		}
+		return left, right
+	}
+
+	tests := []struct {
+		textAlign string
+		check     func(left, right, lastLeft, lastRight int) bool
+	}{
+		{"left", func(left, right, lastLeft, lastRight int) bool { return left < 2 && right < 95 && lastLeft < 2 }},
+		{"right", func(left, right, lastLeft, lastRight int) bool { return left > 5 && right > 95 && lastRight > 95 }},
+		{"center", func(left, right, lastLeft, lastRight int) bool { return left > 2 && lastLeft > 20 && lastRight < 80 }},
+		{"justify", func(left, right, lastLeft, lastRight int) bool {
+			return left < 2 && right > 97 && lastLeft < 2 && lastRight < 80
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.textAlign, func(t *testing.T) {
+			left, right := inkBounds(t, tt.textAlign, 0)
+			lastLeft, lastRight := inkBounds(t, tt.textAlign, 2)
+			if !tt.check(left, right, lastLeft, lastRight) {
+				t.Errorf("unexpected bounds of text %v-%v and of last row %v-%v", left, right, lastLeft, lastRight)
+			}
+		})
+	}
+}
+
+func TestSpans(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+
+	img := renderTemplate(t, `
+size: 200 80
+inner:
+  - width: 150
+    font: 20
+    lineHeight: 40
+    spans:
+      - text: "Total "
+        color: red
+      - text: $42
+        font: 30
+        color: blue
+      - text: ", only today"
+        color: red`)
+
+	// colorBounds returns horizontal bounds of pixels of color in row
+	colorBounds := func(c color.RGBA, row int) (left int, right int) {
+		left, right = -1, -1
+		for x := 0; x < 200; x++ {
+			for y := row * 40; y < row*40+40; y++ {
+				if img.At(x, y) == c {
+					if left == -1 {
+						left = x
+					}
+					right = x
+				}
+			}
+		}
+		return left, right
+	}
+
+	redLeft, redRight := colorBounds(red, 0)
+	blueLeft, blueRight := colorBounds(blue, 0)
+	if redLeft == -1 || blueLeft == -1 || redLeft > blueLeft || redRight < blueRight {
+		t.Errorf("unexpected bounds of spans in first row: red %v-%v, blue %v-%v", redLeft, redRight, blueLeft, blueRight)
+	}
+
+	// Spans are wrapped as one paragraph
+	if left, _ := colorBounds(red, 1); left == -1 {
+		t.Errorf("expected wrapped span in second row")
+	}
+	if left, _ := colorBounds(blue, 1); left != -1 {
+		t.Errorf("unexpected span in second row")
+	}
+}
+
+func TestLetterSpacing(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+
+	runPixelTests(t, []pixelTest{
+		{
+			name: "no spacing",
+			template: `
+size: 100 20
+inner:
+  - font: 10
+    color: red
+    bkgColor: red
+    text: ii`,
+			expected: []pixel{{1, 5, red}, {30, 5, color.RGBA{}}},
+		},
+		{
+			name: "spacing in pixels",
+			template: `
+size: 100 20
+inner:
+  - font: 10
+    color: red
+    bkgColor: red
+    letterSpacing: 20
+    text: ii`,
+			expected: []pixel{{1, 5, red}, {30, 5, red}, {50, 5, color.RGBA{}}},
+		},
+		{
+			name: "spacing in em",
+			template: `
+size: 100 20
+inner:
+  - font: 10
+    color: red
+    bkgColor: red
+    letterSpacing: 2em
+    text: ii`,
+			expected: []pixel{{1, 5, red}, {30, 5, red}, {50, 5, color.RGBA{}}},
+		},
+	})
+}
+
+func TestTextDecoration(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+
+	// lineTop returns top of red line drawn under transparent text, or -1 if there is no line
+	lineTop := func(t *testing.T, decoration string) int {
+		img := renderTemplate(t, fmt.Sprintf(`
+size: 100 20
+inner:
+  - font: 10
+    lineHeight: 20
+    color: 0xffffff00
+    textDecoration: %v
+    text: aaaa bbbb`, decoration))
+
+		for y := 0; y < 20; y++ {
+			// Line continues over whitespace between words
+			if img.At(2, y) == red && img.At(23, y) == red {
+				return y
+			}
+		}
+		return -1
+	}
+
+	overline := lineTop(t, "overline red 1")
+	lineThrough := lineTop(t, "line-through red 1")
+	underline := lineTop(t, "underline red 1")
+
+	if overline == -1 || lineThrough == -1 || underline == -1 || !(overline < lineThrough && lineThrough < underline) {
+		t.Errorf("unexpected positions of lines: overline %v, line-through %v, underline %v", overline, lineThrough, underline)
+	}
+	if none := lineTop(t, "none"); none != -1 {
+		t.Errorf("unexpected line at %v", none)
+	}
+}
+
+func TestTextStrokeAndShadow(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+
+	// redRows returns first and last rows with red pixels of stroke or shadow drawn for transparent text,
+	// or -1 if there are no red pixels
+	redRows := func(t *testing.T, effect string) (first int, last int) {
+		img := renderTemplate(t, fmt.Sprintf(`
+size: 100 60
+inner:
+  - font: 20
+    lineHeight: 20
+    color: 0xffffff00
+    %v
+    text: Hello`, effect))
+
+		first, last = -1, -1
+		for y := 0; y < 60; y++ {
+			for x := 0; x < 100; x++ {
+				if img.At(x, y) == red {
+					if first == -1 {
+						first = y
+					}
+					last = y
+				}
+			}
+		}
+		return first, last
+	}
+
+	if first, last := redRows(t, "textStroke: 3 red"); first == -1 || last >= 25 {
+		t.Errorf("unexpected rows of stroke: %v - %v", first, last)
+	}
+
+	// Shadow is moved below text
+	if first, last := redRows(t, "textShadow: 0 30 red"); first < 30 || last >= 55 {
+		t.Errorf("unexpected rows of shadow: %v - %v", first, last)
+	}
+
+	// Blurred shadow has no pixels of solid color
+	if first, _ := redRows(t, "textShadow: 0 30 10 red"); first != -1 {
+		t.Errorf("unexpected solid pixels of blurred shadow at %v", first)
+	}
+
+	if first, _ := redRows(t, ""); first != -1 {
+		t.Errorf("unexpected red pixels at %v", first)
+	}
+
+	// Spans of different style are separate nodes, effects of span are not drawn over glyphs of other spans
+	render := func(effect string) image.Image {
+		return renderTemplate(t, fmt.Sprintf(`
+size: 100 60
+inner:
+  - font: 30
+    color: black
+    %v
+    spans:
+      - text: W
+      - text: W
+        fontWeight: 700
+      - text: W`, effect))
+	}
+	plain := render("")
+	stroked := render("textStroke: 6 red")
+	black := color.RGBA{A: 255}
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 100; x++ {
+			if plain.At(x, y) == black && stroked.At(x, y) != black {
+				t.Fatalf("glyph pixel %v,%v is covered with stroke: %v", x, y, stroked.At(x, y))
+			}
+		}
+	}
+}
+
+func TestValidation(t *testing.T) {
+	_, err := NewRendererWithTemplate([]byte(`
+size: 100 1o0
+unknown: 1
+inner:
+  - justify: spacebetween
+    bkgColor: reed
+  - border: 1 red dashed
+    absolute: left/10 middle
+    bkgColor: ~ Color
+`), nil)
+
+	var validationErrs ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	expected := []string{
+		`template:2:7: size: malformed value "1o0", expected number with optional %, w or h unit`,
+		`template:3:1: unknown: unknown field`,
+		`template:5:14: justify: invalid value "spacebetween", expected one of start, center, end, space-between, space-evenly`,
+		`template:6:15: bkgColor: error parsing color "reed"`,
+		`template:7:13: border: unknown token dashed in border property`,
+		`template:8:15: absolute: malformed anchor "middle", expected top, right, bottom or left with optional /offset`,
+	}
+	if len(validationErrs) != len(expected) {
+		t.Fatalf("expected %v errors, got %v", len(expected), validationErrs)
+	}
+	for i, e := range validationErrs {
+		if e.Error() != expected[i] {
+			t.Errorf("expected error %v, got %v", expected[i], e.Error())
+		}
+	}
+}
+
+func TestComponentsValidation(t *testing.T) {
+	// Error of components expansion is reported together with other validation errors
+	_, err := NewRendererWithTemplate([]byte(`
+unknown: 1
+inner:
+  - use: missing
+`), nil)
+
+	var validationErrs ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	expected := []string{
+		`template:2:1: unknown: unknown field`,
+		`template:4:10: use: component missing not found`,
+	}
+	if len(validationErrs) != len(expected) {
+		t.Fatalf("expected %v errors, got %v", len(expected), validationErrs)
+	}
+	for i, e := range validationErrs {
+		if e.Error() != expected[i] {
+			t.Errorf("expected error %v, got %v", expected[i], e.Error())
+		}
+	}
+
+	// Components below root of file are not collected, so they are reported instead of being ignored
+	_, err = NewRendererWithTemplate([]byte(`
+components:
+  card:
+    components:
+      badge:
+        text: b
+    text: card
+inner:
+  - components:
+      label:
+        text: l
+  - use: card
+`), nil)
+
+	if !errors.As(err, &validationErrs) {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	expected = []string{
+		`template:5:7: components: components can be declared only at root of file`,
+		`template:10:7: components: components can be declared only at root of file`,
+	}
+	if len(validationErrs) != len(expected) {
+		t.Fatalf("expected %v errors, got %v", len(expected), validationErrs)
+	}
+	for i, e := range validationErrs {
+		if e.Error() != expected[i] {
+			t.Errorf("expected error %v, got %v", expected[i], e.Error())
+		}
+	}
+}
+
+func TestSpansValidation(t *testing.T) {
+	_, err := NewRendererWithTemplate([]byte(`
+components:
+  badge:
+    bkgColor: red
+    text: new
+inner:
+  - text: Total
+    spans:
+      - text: "Price: "
+        size: 10 10
+        inner:
+          - text: $42
+      - use: badge
+`), nil)
+
+	var validationErrs ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	expected := []string{
+		`template:4:15: bkgColor: field can't be used in span`,
+		`template:7:11: text: text can't be used together with spans`,
+		`template:10:15: size: field can't be used in span`,
+		`template:12:11: inner: field can't be used in span`,
+	}
+	if len(validationErrs) != len(expected) {
+		t.Fatalf("expected %v errors, got %v", len(expected), validationErrs)
+	}
+	for i, e := range validationErrs {
+		if e.Error() != expected[i] {
+			t.Errorf("expected error %v, got %v", expected[i], e.Error())
+		}
+	}
+}
+
+func TestFitFontSizeValidation(t *testing.T) {
+	_, err := NewRendererWithTemplate([]byte(`
+width: 100
+inner:
+  - fontSize: fit
+    text: Unbounded height
+  - height: 20
+    fontSize: fit
+    text: Bounded
+  - innerDirection: grid
+    gridColumns: 1fr
+    inner:
+      - fontSize: fit
+        text: Cell
+  - height: 20
+    fontSize: fit
+    spans:
+      - text: Total
+      - text: $42
+        fontSize: fit
+`), nil)
+
+	var validationErrs ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+
+	expected := []string{
+		`template:4:15: fontSize: fit needs width and height of node or of one of its parents`,
+		`template:15:15: fontSize: fit can't be used together with spans`,
+		`template:19:19: fontSize: fit can't be used in span`,
+	}
+	if len(validationErrs) != len(expected) {
+		t.Fatalf("expected %v errors, got %v", len(expected), validationErrs)
+	}
+	for i, e := range validationErrs {
+		if e.Error() != expected[i] {
+			t.Errorf("expected error %v, got %v", expected[i], e.Error())
+		}
+	}
+}
+
+func TestExpressionsCheck(t *testing.T) {
+	template := []byte(`
+inner:
+  - text: ~ Usre.Name
+  - text: ~ User.Name +
+  - forEach: Items
+    text: ~ value.Titel + string(index)
+  - forEach: Itemz
+`)
+
+	type data struct {
+		User  struct{ Name string }
+		Items []struct{ Title string }
+	}
+
+	tests := []struct {
+		dataType reflect.Type
+		expected []string
+	}{
+		{
+			dataType: nil,
+			expected: []string{
+				`template:4:11: text: expression "User.Name +": unexpected token EOF`,
+			},
+		},
+		{
+			dataType: reflect.TypeOf(data{}),
+			expected: []string{
+				`template:3:11: text: expression "Usre.Name": unknown name Usre`,
+				`template:4:11: text: expression "User.Name +": unexpected token EOF`,
+				`template:6:11: text: expression "value.Titel + string(index)": type struct { Title string } has no field Titel`,
+				`template:7:14: forEach: field Itemz not found in type decorender.data`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := NewRendererWithTemplate(template, &Options{DataType: tt.dataType})
+
+		var validationErrs ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			t.Fatalf("expected validation errors, got %v", err)
+		}
+		if len(validationErrs) != len(tt.expected) {
+			t.Fatalf("expected %v errors, got %v", len(tt.expected), validationErrs)
+		}
+		for i, e := range validationErrs {
+			if e.Error() != tt.expected[i] {
+				t.Errorf("expected error %v, got %v", tt.expected[i], e.Error())
+			}
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	functions := map[string]any{
+		"pick": func(isRed bool, alpha float64) string {
+			if isRed {
+				return fmt.Sprintf("rgba(255, 0, 0, %v)", alpha)
+			}
+			return "blue"
+		},
+		"width": func(n int) (int, error) {
+			if n < 0 {
+				return 0, errors.New("negative width")
+			}
+			return n, nil
+		},
+	}
+
+	d, err := NewRendererWithTemplate([]byte(`
+size: ~ string(width(W)) + ' 1'
+bkgColor: ~ pick(Red, 1)
+`), &Options{Functions: functions})
+	if err != nil {
+		t.Fatalf("unexpected error while yaml parse: %v", err)
+	}
+
+	img, release, err := d.Render(map[string]any{"W": 2, "Red": true}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error while rendering: %v", err)
+	}
+	if img.Bounds().Dx() != 2 || img.At(1, 0) != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("unexpected image %v with color %v", img.Bounds(), img.At(1, 0))
+	}
+	release()
+
+	type data struct {
+		W   string
+		Red bool
+	}
+	_, err = NewRendererWithTemplate([]byte(`
+size: ~ string(width(W)) + ' 1'
+`), &Options{Functions: functions, DataType: reflect.TypeOf(data{})})
+	expected := `template:2:7: size: expression "string(width(W)) + ' 1'": cannot use string as argument (type int) to call width`
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %v, got %v", expected, err)
+	}
+
+	_, err = NewRendererWithTemplate([]byte(`size: 1 1`), &Options{Functions: map[string]any{"pick": "red"}})
+	if err == nil {
+		t.Errorf("expected error for function that is not func")
+	}
+}
+
+func TestLocale(t *testing.T) {
+	d, err := NewRendererWithTemplate([]byte(`
+size: 1 1
+bkgColor: "~ number(Price) == '1.234,5' ? 'red' : 'blue'"
+`), &Options{Locale: "de-DE"})
+	if err != nil {
+		t.Fatalf("unexpected error while yaml parse: %v", err)
+	}
+
+	tests := []struct {
+		locale   string
+		expected color.RGBA
+	}{
+		{locale: "", expected: color.RGBA{R: 255, A: 255}},
+		{locale: "en-US", expected: color.RGBA{B: 255, A: 255}},
+		{locale: "de", expected: color.RGBA{R: 255, A: 255}},
+	}
+
+	for _, tt := range tests {
+		img, release, err := d.Render(map[string]any{"Price": 1234.5}, &RenderOptions{Locale: tt.locale})
+		if err != nil {
+			t.Fatalf("unexpected error while rendering: %v", err)
+		}
+		if img.At(0, 0) != tt.expected {
+			t.Errorf("locale %v: unexpected color %v, expected %v", tt.locale, img.At(0, 0), tt.expected)
+		}
+		release()
+	}
+
+	if _, _, err = d.Render(nil, &RenderOptions{Locale: "not a locale"}); err == nil {
+		t.Errorf("expected error for invalid locale")
+	}
+
+	// Templates compiled for other locales are not kept forever
+	for _, region := range []string{"AT", "CH", "BE", "LU", "LI", "IT", "DK", "PL", "NL", "FR", "CZ", "SK", "HU", "SI", "HR", "RO", "BG", "GR", "ES", "PT", "SE", "NO", "FI"} {
+		_, release, err := d.Render(map[string]any{"Price": 1234.5}, &RenderOptions{Locale: "de-" + region})
+		if err != nil {
+			t.Fatalf("unexpected error while rendering: %v", err)
+		}
+		release()
+	}
+	if n := d.templates.Len(false); n > templatesCacheSize {
+		t.Errorf("expected at most %v compiled templates, got %v", templatesCacheSize, n)
+	}
+}
+
+func TestTranslations(t *testing.T) {
+	files := fstest.MapFS{
+		"en.yaml": {Data: []byte(`color: blue`)},
+		"de.yaml": {Data: []byte(`{"color": "red", "title": "Titel"}`)},
+	}
+
+	d, err := NewRendererWithTemplate([]byte(`
+translations:
+  en: en.yaml
+  de: de.yaml
+size: 1 1
+bkgColor: ~ t("color")
+text: ~ t("title")
+`), &Options{LocalFiles: files, Language: "en"})
+	if err != nil {
+		t.Fatalf("unexpected error while yaml parse: %v", err)
+	}
+
+	expectedWarning := `template:7:7: text: missing translation of "title" for en`
+	if warnings := d.Warnings(); len(warnings) != 1 || warnings[0].Error() != expectedWarning {
+		t.Errorf("expected warning %v, got %v", expectedWarning, warnings)
+	}
+
+	tests := []struct {
+		opts     *RenderOptions
+		expected color.RGBA
+	}{
+		{opts: nil, expected: color.RGBA{B: 255, A: 255}},
+		{opts: &RenderOptions{Language: "de"}, expected: color.RGBA{R: 255, A: 255}},
+		{opts: &RenderOptions{Locale: "de-AT"}, expected: color.RGBA{R: 255, A: 255}},
+		{opts: &RenderOptions{Language: "ja"}, expected: color.RGBA{B: 255, A: 255}},
+	}
+
+	for _, tt := range tests {
+		img, release, err := d.Render(map[string]any{}, tt.opts)
+		if err != nil {
+			t.Fatalf("unexpected error while rendering: %v", err)
+		}
+		if img.At(0, 0) != tt.expected {
+			t.Errorf("options %+v: unexpected color %v, expected %v", tt.opts, img.At(0, 0), tt.expected)
+		}
+		release()
+	}
+
+	if files := d.IncludedFiles(); len(files) != 2 {
+		t.Errorf("expected translations files to be included, got %v", files)
+	}
+}
+
+func TestLoadIncludedFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"layout.yaml":          "translations:\n  en: en.yaml\ninner:\n  - include: partials/footer.yaml\n",
+		"en.yaml":              "title: Title\n",
+		"partials/footer.yaml": "inner:\n  - include: broken.yaml\n",
+		"partials/broken.yaml": "inner: [\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Renderer can't be created, but files read before error are still returned
+	opts := &Options{LocalFiles: os.DirFS(dir)}
+	if _, err := NewRenderer(filepath.Join(dir, "layout.yaml"), opts); err == nil {
+		t.Fatalf("expected error of broken include")
+	}
+	includedFiles, err := LoadIncludedFiles(filepath.Join(dir, "layout.yaml"), opts)
+	if err == nil {
+		t.Errorf("expected error of broken include")
+	}
+	expected := []string{"partials/footer.yaml", "partials/broken.yaml", "en.yaml"}
+	if !reflect.DeepEqual(includedFiles, expected) {
+		t.Errorf("unexpected included files %v, expected %v", includedFiles, expected)
+	}
+}
+
+// BenchmarkExpressions renders template where most of the time is spent in expressions evaluation.
+// Run with -cpu 1,2,4,8 to see how it scales with concurrent renders.
+func BenchmarkExpressions(b *testing.B) {
+	d, err := NewRendererWithTemplate([]byte(`
+size: 10 10
+inner:
+  - forEach: Items
+    size: ~ string(value.W) + ' ' + string(value.H)
+    bkgColor: "~ index % 2 == 0 ? 'red' : 'blue'"
+    padding: ~ value.W / 10
+    inner:
+      - size: ~ string(value.W) + ' 1'
+        bkgColor: ~ value.Color
+`), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	type item struct {
+		W, H  int
+		Color string
+	}
+	data := struct{ Items []item }{}
+	for i := 0; i < 100; i++ {
+		data.Items = append(data.Items, item{W: 1, H: 1, Color: "green"})
+	}
+
+	// Broken expressions would be benchmarked as fast ones, so render must succeed
+	if err := d.RenderAndWrite(&data, EncodeFormatNone, nil, nil); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			// Fatal can't be called from goroutines of RunParallel
+			if err := d.RenderAndWrite(&data, EncodeFormatNone, nil, nil); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -7,6 +7,7 @@ require (
 	github.com/bluele/gcache v0.0.2
 	github.com/disintegration/imaging v1.6.2
 	github.com/fsnotify/fsnotify v1.7.0
+	github.com/go-text/typesetting v0.2.1
 	github.com/nasa9084/go-builderpool v0.0.0-20210914072601-0ff03b34a097
 	github.com/samber/lo v1.38.1
 	github.com/stretchr/testify v1.8.4
@@ -1,8 +1,10 @@
 package fonts
 
 import (
+	"bytes"
 	_ "embed"
 	"fmt"
+	gotext "github.com/go-text/typesetting/font"
 	"github.com/godknowsiamgood/decorender/internal/parsing"
 	"golang.org/x/exp/slices"
 	"golang.org/x/image/font"
@@ -20,10 +22,13 @@ var defaultFontFile []byte
 const DefaultFamily = "Roboto"
 
 type FaceDescription struct {
-	Family string
-	Size   float64
-	Weight int
-	Style  font.Style
+	Family        string
+	Size          float64
+	Weight        int
+	Style         font.Style
+	LetterSpacing float64 // added after every glyph
+	Features      string  // OpenType features applied while shaping, see ParseFontFeatures
+	Language      string  // BCP 47 language of text used while shaping, default language if empty
 }
 
 type loadedFontFace struct {
@@ -31,7 +36,9 @@ type loadedFontFace struct {
 	style  font.Style
 	weight int
 	font   *opentype.Font
-	uri    string
+	// shapingFont is the same font parsed for shaping
+	shapingFont *gotext.Font
+	uri         string
 }
 
 var loadedFaces []loadedFontFace
@@ -39,16 +46,24 @@ var loadedFacesMx sync.RWMutex
 
 // GetFont returns font nearest by it`s weight
 func GetFont(fd FaceDescription) (*opentype.Font, error) {
+	lf, err := getLoadedFace(fd)
+	if err != nil {
+		return nil, err
+	}
+	return lf.font, nil
+}
+
+func getLoadedFace(fd FaceDescription) (*loadedFontFace, error) {
 	loadedFacesMx.RLock()
 	defer loadedFacesMx.RUnlock()
 
 	minWeightDiff := 9999999.0
 	var currentFace *loadedFontFace
-	for _, f := range loadedFaces {
-		f := f
+	for i := range loadedFaces {
+		f := &loadedFaces[i]
 		weightDiff := math.Abs(float64(f.weight - fd.Weight))
 		if weightDiff < minWeightDiff && fd.Family == f.family && fd.Style == f.style {
-			currentFace = &f
+			currentFace = f
 			minWeightDiff = weightDiff
 		}
 	}
@@ -60,7 +75,7 @@ func GetFont(fd FaceDescription) (*opentype.Font, error) {
 		currentFace = &loadedFaces[0] // first is the default face
 	}
 
-	return currentFace.font, nil
+	return currentFace, nil
 }
 
 func GetFontFace(fd FaceDescription) (font.Face, error) {
@@ -79,19 +94,23 @@ func GetFontFace(fd FaceDescription) (font.Face, error) {
 }
 
 func MeasureTextWidth(text string, fd FaceDescription) float64 {
-	face, err := GetFontFace(fd)
+	shaped, upem, err := shapeText(text, fd)
 	if err != nil {
 		return 0.0
 	}
+	return getShapedTextWidth(shaped, upem, fd)
+}
 
-	var width float64
-
-	for _, runeValue := range []rune(text) {
-		advance, _ := face.GlyphAdvance(runeValue)
-		width += float64(advance)
+// GetUnderlineMetrics returns offset of top of underline below baseline and its thickness suggested by font
+func GetUnderlineMetrics(fd FaceDescription) (position float64, thickness float64) {
+	f, err := GetFont(fd)
+	if err != nil || f.PostTable() == nil || f.UnitsPerEm() == 0 {
+		return fd.Size / 10, fd.Size / 15
 	}
 
-	return width / 64 // Convert from 26.6 fixed-point to float64
+	post := f.PostTable()
+	scale := fd.Size / float64(f.UnitsPerEm())
+	return -float64(post.UnderlinePosition) * scale, float64(post.UnderlineThickness) * scale
 }
 
 func GetFontFaceBaseLineOffset(face font.Face, lineHeight float64) float64 {
@@ -178,6 +197,12 @@ func loadFont(faceTemplate parsing.FontFace, content []byte, fs fs.FS) error {
 
 		cff.font = fnt
 
+		shapingFace, err := gotext.ParseTTF(bytes.NewReader(content))
+		if err != nil {
+			return fmt.Errorf("can't parse font file %v", faceTemplate.File)
+		}
+		cff.shapingFont = shapingFace.Font
+
 		loadedFaces = append(loadedFaces, cff)
 	}
 
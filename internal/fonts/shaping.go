@@ -0,0 +1,204 @@
+package fonts
+
+import (
+	"fmt"
+	"github.com/bluele/gcache"
+	"github.com/go-text/typesetting/di"
+	gotext "github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/language"
+	"github.com/go-text/typesetting/shaping"
+	"github.com/godknowsiamgood/decorender/internal/utils"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Glyph is a glyph of shaped text
+type Glyph struct {
+	Index sfnt.GlyphIndex
+	// X and Y are position of glyph origin relative to start of text on baseline, Y increases downwards
+	X, Y float64
+}
+
+// shapedGlyph is a glyph of shaped text in font units, so shaped text can be reused for any font size
+type shapedGlyph struct {
+	index     sfnt.GlyphIndex
+	advance   float64
+	offsetX   float64
+	offsetY   float64
+	runeCount int
+}
+
+// shapingKey is keyed by loaded font rather than by its family, as face description with the same family
+// may match other font when more faces are loaded. Script and direction of runs are detected from text,
+// so they are keyed by text itself.
+type shapingKey struct {
+	text     string
+	font     *gotext.Font
+	features string
+	language string
+}
+
+var shapedTexts = gcache.New(10000).LRU().Build()
+
+type shaper struct {
+	shaping.HarfbuzzShaper
+	segmenter shaping.Segmenter
+}
+
+var shapersPool = sync.Pool{
+	New: func() any {
+		return &shaper{}
+	},
+}
+
+// singleFontmap resolves every rune to the same face, as fallback fonts are not supported
+type singleFontmap struct {
+	face *gotext.Face
+}
+
+func (m singleFontmap) ResolveFace(rune) *gotext.Face {
+	return m.face
+}
+
+// ShapeText returns positioned glyphs of text with kerning, ligatures and font features applied,
+// and width of text including letter spacing, that is added after every rune.
+func ShapeText(text string, fd FaceDescription) ([]Glyph, float64, error) {
+	shaped, upem, err := shapeText(text, fd)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	scale := fd.Size / upem
+	glyphs := make([]Glyph, len(shaped))
+	var x float64
+	for i, g := range shaped {
+		glyphs[i] = Glyph{
+			Index: g.index,
+			X:     x + g.offsetX*scale,
+			Y:     -g.offsetY * scale,
+		}
+		x += g.advance*scale + fd.LetterSpacing*float64(g.runeCount)
+	}
+
+	return glyphs, x, nil
+}
+
+// getShapedTextWidth returns the same width as ShapeText, without positioning glyphs
+func getShapedTextWidth(shaped []shapedGlyph, upem float64, fd FaceDescription) float64 {
+	scale := fd.Size / upem
+	var x float64
+	for _, g := range shaped {
+		x += g.advance*scale + fd.LetterSpacing*float64(g.runeCount)
+	}
+	return x
+}
+
+func shapeText(text string, fd FaceDescription) ([]shapedGlyph, float64, error) {
+	lf, err := getLoadedFace(fd)
+	if err != nil {
+		return nil, 0, err
+	}
+	upem := float64(lf.shapingFont.Upem())
+
+	key := shapingKey{text: text, font: lf.shapingFont, features: fd.Features, language: fd.Language}
+	if v, err := shapedTexts.Get(key); err == nil {
+		return v.([]shapedGlyph), upem, nil
+	}
+
+	features, err := ParseFontFeatures(fd.Features)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	runes := []rune(text)
+	for i, r := range runes {
+		runes[i] = utils.SimplifyRune(r)
+	}
+
+	lang := language.DefaultLanguage()
+	if fd.Language != "" {
+		lang = language.NewLanguage(fd.Language)
+	}
+
+	s := shapersPool.Get().(*shaper)
+	defer shapersPool.Put(s)
+
+	var shaped []shapedGlyph
+	for _, input := range splitText(s, runes, gotext.NewFace(lf.shapingFont), lang) {
+		// Text is shaped with size of em in font units, since shaper rounds sizes up to whole pixels
+		input.FontFeatures = features
+		input.Size = fixed.I(int(upem))
+		// Runs without specific script, like numbers, are shaped as latin text
+		if input.Script == language.Common {
+			input.Script = language.Latin
+		}
+		output := s.Shape(input)
+
+		for i, g := range output.Glyphs {
+			sg := shapedGlyph{
+				index:     sfnt.GlyphIndex(g.GlyphID),
+				advance:   float64(g.XAdvance) / 64,
+				offsetX:   float64(g.XOffset) / 64,
+				offsetY:   float64(g.YOffset) / 64,
+				runeCount: g.RuneCount,
+			}
+			// Runes of cluster are counted once
+			if i > 0 && g.ClusterIndex == output.Glyphs[i-1].ClusterIndex {
+				sg.runeCount = 0
+			}
+			shaped = append(shaped, sg)
+		}
+	}
+
+	_ = shapedTexts.Set(key, shaped)
+
+	return shaped, upem, nil
+}
+
+// splitText splits text into runs of the same direction and script, that are shaped separately.
+// Runs are returned in order of text, glyphs of right-to-left runs are reversed by shaper.
+func splitText(s *shaper, runes []rune, face *gotext.Face, lang language.Language) []shaping.Input {
+	return s.segmenter.Split(shaping.Input{
+		Text:      runes,
+		RunStart:  0,
+		RunEnd:    len(runes),
+		Direction: di.DirectionLTR,
+		Face:      face,
+		Language:  lang,
+	}, singleFontmap{face: face})
+}
+
+// ParseFontFeatures parses OpenType features separated by spaces or commas, e.g. "tnum -liga ss01=2".
+// Feature is enabled by its tag or with + prefix, disabled with - prefix, or set to value after =.
+func ParseFontFeatures(value string) ([]shaping.FontFeature, error) {
+	tokens := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+
+	features := make([]shaping.FontFeature, 0, len(tokens))
+	for _, t := range tokens {
+		tag, featureValue := t, uint32(1)
+		if strings.HasPrefix(t, "-") {
+			tag, featureValue = t[1:], 0
+		} else if strings.HasPrefix(t, "+") {
+			tag = t[1:]
+		} else if i := strings.IndexByte(t, '='); i != -1 {
+			v, err := strconv.ParseUint(t[i+1:], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("malformed value of font feature \"%v\"", t)
+			}
+			tag, featureValue = t[:i], uint32(v)
+		}
+
+		if len(tag) != 4 {
+			return nil, fmt.Errorf("malformed font feature \"%v\", expected 4 letters tag", t)
+		}
+		features = append(features, shaping.FontFeature{Tag: opentype.MustNewTag(tag), Value: featureValue})
+	}
+
+	return features, nil
+}
@@ -0,0 +1,79 @@
+package fonts
+
+import (
+	"testing"
+
+	"github.com/go-text/typesetting/di"
+	gotext "github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/font/opentype"
+	"github.com/go-text/typesetting/language"
+	"github.com/go-text/typesetting/shaping"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShapeText(t *testing.T) {
+	assert.NoError(t, LoadFaces(nil, nil))
+	fd := FaceDescription{Family: DefaultFamily, Size: 20, Weight: 400}
+
+	// Pair of glyphs is kerned
+	assert.Less(t, MeasureTextWidth("AV", fd), MeasureTextWidth("A", fd)+MeasureTextWidth("V", fd))
+
+	// Standard ligature replaces two glyphs with one
+	glyphs, _, err := ShapeText("fi", fd)
+	assert.NoError(t, err)
+	assert.Len(t, glyphs, 1)
+
+	fd.Features = "-liga -kern"
+	glyphs, _, err = ShapeText("fi", fd)
+	assert.NoError(t, err)
+	assert.Len(t, glyphs, 2)
+	assert.Equal(t, MeasureTextWidth("A", fd)+MeasureTextWidth("V", fd), MeasureTextWidth("AV", fd))
+
+	// Letter spacing is added after every rune, even if runes are shaped into ligature
+	fd.Features = ""
+	width := MeasureTextWidth("fi", fd)
+	fd.LetterSpacing = 2
+	assert.InDelta(t, width+4, MeasureTextWidth("fi", fd), 0.001)
+
+	// Shaped text is scaled to any font size
+	fd.LetterSpacing = 0
+	fd.Size = 40
+	assert.InDelta(t, width*2, MeasureTextWidth("fi", fd), 0.001)
+}
+
+func TestParseFontFeatures(t *testing.T) {
+	features, err := ParseFontFeatures("tnum, -liga +kern ss01=2")
+	assert.NoError(t, err)
+	assert.Equal(t, []shaping.FontFeature{
+		{Tag: opentype.MustNewTag("tnum"), Value: 1},
+		{Tag: opentype.MustNewTag("liga"), Value: 0},
+		{Tag: opentype.MustNewTag("kern"), Value: 1},
+		{Tag: opentype.MustNewTag("ss01"), Value: 2},
+	}, features)
+
+	for _, v := range []string{"tabular", "-", "ss01=x", "ss01=-1"} {
+		_, err = ParseFontFeatures(v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	assert.NoError(t, LoadFaces(nil, nil))
+	face := gotext.NewFace(loadedFaces[0].shapingFont)
+	lang := language.NewLanguage("he-IL")
+
+	// Runs of other script are split, and right-to-left script is shaped in its direction
+	runes := []rune("abc אבג")
+	inputs := splitText(&shaper{}, runes, face, lang)
+	assert.Len(t, inputs, 2)
+	assert.Equal(t, "abc ", string(runes[inputs[0].RunStart:inputs[0].RunEnd]))
+	assert.Equal(t, di.DirectionLTR, inputs[0].Direction)
+	assert.Equal(t, language.Latin, inputs[0].Script)
+	assert.Equal(t, "אבג", string(runes[inputs[1].RunStart:inputs[1].RunEnd]))
+	assert.Equal(t, di.DirectionRTL, inputs[1].Direction)
+	assert.Equal(t, language.Hebrew, inputs[1].Script)
+
+	for _, input := range inputs {
+		assert.Equal(t, lang, input.Language)
+	}
+}
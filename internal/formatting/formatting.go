@@ -0,0 +1,222 @@
+// Package formatting contains locale-aware formatting of numbers, currencies and dates for template expressions
+package formatting
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// DefaultLocale is used when no locale is specified
+var DefaultLocale = language.AmericanEnglish
+
+// nbsp keeps currency symbol and amount on the same line
+const nbsp = "\u00a0"
+
+// Languages where currency symbol is placed after amount, e.g. "1.234,50 €"
+var currencySuffixLanguages = []string{
+	"bg", "cs", "da", "de", "el", "es", "et", "fi", "fr", "hr", "hu", "is", "it", "lt", "lv",
+	"nb", "nn", "no", "pl", "pt-PT", "ro", "ru", "sk", "sl", "sr", "sv", "uk",
+}
+
+// Numeric date layouts by language or region, in Go time layout format
+var dateLayouts = map[string]string{
+	"en":    "01/02/2006",
+	"en-GB": "02/01/2006",
+	"en-AU": "02/01/2006",
+	"en-IN": "02/01/2006",
+	"en-CA": "2006-01-02",
+	"de":    "02.01.2006",
+	"ru":    "02.01.2006",
+	"uk":    "02.01.2006",
+	"pl":    "02.01.2006",
+	"cs":    "02.01.2006",
+	"fi":    "02.01.2006",
+	"nb":    "02.01.2006",
+	"tr":    "02.01.2006",
+	"fr":    "02/01/2006",
+	"es":    "02/01/2006",
+	"it":    "02/01/2006",
+	"pt":    "02/01/2006",
+	"nl":    "02-01-2006",
+	"da":    "02.01.2006",
+	"sv":    "2006-01-02",
+	"ja":    "2006/01/02",
+	"zh":    "2006/01/02",
+	"ko":    "2006. 01. 02.",
+	"hu":    "2006. 01. 02.",
+}
+
+// ParseLocale parses BCP 47 locale, e.g. "de-DE". Empty locale is DefaultLocale.
+func ParseLocale(locale string) (language.Tag, error) {
+	if locale == "" {
+		return DefaultLocale, nil
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return DefaultLocale, fmt.Errorf("invalid locale \"%v\"", locale)
+	}
+	return tag, nil
+}
+
+// Number formats number with locale grouping and decimal separators, e.g. 1234.5 is "1.234,5" in de-DE
+func Number(v any, tag language.Tag) (string, error) {
+	f, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	return message.NewPrinter(tag).Sprint(number.Decimal(f)), nil
+}
+
+// Percent formats fraction as percent, e.g. 0.25 is "25 %" in de-DE
+func Percent(v any, tag language.Tag) (string, error) {
+	f, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+	return message.NewPrinter(tag).Sprint(number.Percent(f, number.MaxFractionDigits(1))), nil
+}
+
+// Currency formats amount with currency symbol and standard number of fraction digits of currency,
+// e.g. 1234.5 EUR is "€1,234.50" in en-US and "1.234,50 €" in de-DE
+func Currency(v any, code string, tag language.Tag) (string, error) {
+	f, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return "", fmt.Errorf("unknown currency \"%v\"", code)
+	}
+
+	p := message.NewPrinter(tag)
+	scale, _ := currency.Standard.Rounding(unit)
+	amount := p.Sprint(number.Decimal(math.Abs(f), number.Scale(scale)))
+	symbol := p.Sprint(currency.Symbol(unit))
+
+	sign := ""
+	if f < 0 {
+		sign = "-"
+	}
+
+	if matchesLanguage(tag, currencySuffixLanguages) {
+		return sign + amount + nbsp + symbol, nil
+	}
+
+	// Letter codes like "CHF" are separated from amount
+	if r, _ := utf8.DecodeLastRuneInString(symbol); unicode.IsLetter(r) {
+		return sign + symbol + nbsp + amount, nil
+	}
+
+	return sign + symbol + amount, nil
+}
+
+// Date formats time with Go layout, e.g. "2006-01-02".
+// If layout is empty, numeric date format of locale is used. Names of months and weekdays
+// in layout, e.g. "January" or "Mon", are always English, as Go time layouts are.
+// Time can be time.Time, RFC 3339 string or unix timestamp in seconds.
+func Date(v any, layout string, tag language.Tag) (string, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return "", err
+	}
+
+	if layout == "" {
+		layout = getDateLayout(tag)
+	}
+
+	return t.Format(layout), nil
+}
+
+func getDateLayout(tag language.Tag) string {
+	base, _ := tag.Base()
+	region, _ := tag.Region()
+
+	if layout, has := dateLayouts[base.String()+"-"+region.String()]; has {
+		return layout
+	}
+	if layout, has := dateLayouts[base.String()]; has {
+		return layout
+	}
+	return "2006-01-02"
+}
+
+// matchesLanguage checks if tag matches one of languages, that are either base language ("de")
+// or language with region ("pt-PT")
+func matchesLanguage(tag language.Tag, languages []string) bool {
+	base, _ := tag.Base()
+	region, _ := tag.Region()
+	return slices.Contains(languages, base.String()) || slices.Contains(languages, base.String()+"-"+region.String())
+}
+
+func toFloat(v any) (float64, error) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), nil
+	case int8:
+		return float64(n), nil
+	case int16:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint:
+		return float64(n), nil
+	case uint8:
+		return float64(n), nil
+	case uint16:
+		return float64(n), nil
+	case uint32:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case float32:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return 0, fmt.Errorf("can't format \"%v\" as number", n)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("can't format %T as number", v)
+	}
+}
+
+func toTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case *time.Time:
+		if t == nil {
+			return time.Time{}, fmt.Errorf("can't format nil as time")
+		}
+		return *t, nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("can't format \"%v\" as time, expected RFC 3339", t)
+		}
+		return parsed, nil
+	default:
+		seconds, err := toFloat(v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("can't format %T as time", v)
+		}
+		sec, frac := math.Modf(seconds)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	}
+}
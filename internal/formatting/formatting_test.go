@@ -0,0 +1,127 @@
+package formatting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		value    any
+		locale   string
+		expected string
+	}{
+		{value: 1234.5, locale: "en-US", expected: "1,234.5"},
+		{value: 1234.5, locale: "de-DE", expected: "1.234,5"},
+		{value: 1234567, locale: "hi-IN", expected: "12,34,567"},
+		{value: "-42", locale: "en-US", expected: "-42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			result, err := Number(tt.value, language.MustParse(tt.locale))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	_, err := Number(true, DefaultLocale)
+	assert.Error(t, err)
+}
+
+func TestCurrency(t *testing.T) {
+	tests := []struct {
+		value    float64
+		code     string
+		locale   string
+		expected string
+	}{
+		{value: 1234.5, code: "EUR", locale: "en-US", expected: "€1,234.50"},
+		{value: 1234.5, code: "EUR", locale: "de-DE", expected: "1.234,50\u00a0€"},
+		{value: -5, code: "USD", locale: "en-US", expected: "-$5.00"},
+		{value: 1234, code: "JPY", locale: "ja", expected: "￥1,234"},
+		{value: 10, code: "CHF", locale: "en-US", expected: "CHF\u00a010.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code+" "+tt.locale, func(t *testing.T) {
+			result, err := Currency(tt.value, tt.code, language.MustParse(tt.locale))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	_, err := Currency(1, "XXXX", DefaultLocale)
+	assert.Error(t, err)
+}
+
+func TestPercent(t *testing.T) {
+	result, err := Percent(0.256, language.MustParse("en-US"))
+	assert.NoError(t, err)
+	assert.Equal(t, "25.6%", result)
+
+	result, err = Percent(0.25, language.MustParse("de-DE"))
+	assert.NoError(t, err)
+	assert.Equal(t, "25\u00a0%", result)
+}
+
+func TestDate(t *testing.T) {
+	date := time.Date(2024, 3, 9, 15, 4, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    any
+		layout   string
+		locale   string
+		expected string
+	}{
+		{value: date, layout: "2006-01-02 15:04", locale: "en-US", expected: "2024-03-09 15:04"},
+		{value: date, locale: "en-US", expected: "03/09/2024"},
+		{value: date, locale: "en-GB", expected: "09/03/2024"},
+		{value: date, locale: "de-DE", expected: "09.03.2024"},
+		{value: "2024-03-09T15:04:00Z", locale: "ja", expected: "2024/03/09"},
+		{value: date.Unix(), locale: "sw", expected: "2024-03-09"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			result, err := Date(tt.value, tt.layout, language.MustParse(tt.locale))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2024, 3, 9, 15, 4, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    time.Time
+		locale   string
+		expected string
+	}{
+		{value: now, locale: "en", expected: "now"},
+		{value: now.Add(-3 * time.Hour), locale: "en", expected: "3 hours ago"},
+		{value: now.Add(24 * time.Hour), locale: "en", expected: "in 1 day"},
+		{value: now.Add(-90 * time.Second), locale: "de", expected: "vor 1 Minute"},
+		{value: now.Add(400 * 24 * time.Hour), locale: "fr", expected: "dans 1 an"},
+		{value: now.Add(-5 * 24 * time.Hour), locale: "ja", expected: "5 days ago"},
+		{value: now.Add(-21 * 24 * time.Hour), locale: "ru", expected: "21 день назад"},
+		{value: now.Add(-3 * 24 * time.Hour), locale: "ru", expected: "3 дня назад"},
+		{value: now.Add(-12 * 24 * time.Hour), locale: "ru", expected: "12 дней назад"},
+		{value: now.Add(22 * time.Minute), locale: "pl", expected: "za 22 minuty"},
+		{value: now.Add(-2 * time.Hour), locale: "uk", expected: "2 години тому"},
+		{value: now.Add(-2 * 24 * time.Hour), locale: "cs", expected: "před 2 dny"},
+		{value: now.Add(5 * 24 * time.Hour), locale: "cs", expected: "za 5 dní"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			result, err := RelativeTime(tt.value, now, language.MustParse(tt.locale))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package formatting
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// unitForms are words of time unit by CLDR plural form. Other form is used for forms that are not listed.
+type unitForms map[plural.Form]string
+
+func (f unitForms) get(form plural.Form) string {
+	if word, has := f[form]; has {
+		return word
+	}
+	return f[plural.Other]
+}
+
+// relativeTimeWords are words of relative time for particular language.
+// Units are seconds, minutes, hours, days, months and years. Languages where units are declined
+// differently in past, e.g. Czech "před 2 dny" and "za 2 dny", have separate pastUnits.
+type relativeTimeWords struct {
+	now       string
+	past      string
+	future    string
+	units     [6]unitForms
+	pastUnits [6]unitForms
+}
+
+var relativeTimeLanguages = map[string]relativeTimeWords{
+	"en": {
+		now: "now", past: "%v ago", future: "in %v",
+		units: [6]unitForms{
+			{plural.One: "second", plural.Other: "seconds"},
+			{plural.One: "minute", plural.Other: "minutes"},
+			{plural.One: "hour", plural.Other: "hours"},
+			{plural.One: "day", plural.Other: "days"},
+			{plural.One: "month", plural.Other: "months"},
+			{plural.One: "year", plural.Other: "years"},
+		},
+	},
+	"de": {
+		now: "jetzt", past: "vor %v", future: "in %v",
+		units: [6]unitForms{
+			{plural.One: "Sekunde", plural.Other: "Sekunden"},
+			{plural.One: "Minute", plural.Other: "Minuten"},
+			{plural.One: "Stunde", plural.Other: "Stunden"},
+			{plural.One: "Tag", plural.Other: "Tagen"},
+			{plural.One: "Monat", plural.Other: "Monaten"},
+			{plural.One: "Jahr", plural.Other: "Jahren"},
+		},
+	},
+	"fr": {
+		now: "maintenant", past: "il y a %v", future: "dans %v",
+		units: [6]unitForms{
+			{plural.One: "seconde", plural.Other: "secondes"},
+			{plural.One: "minute", plural.Other: "minutes"},
+			{plural.One: "heure", plural.Other: "heures"},
+			{plural.One: "jour", plural.Other: "jours"},
+			{plural.Other: "mois"},
+			{plural.One: "an", plural.Other: "ans"},
+		},
+	},
+	"es": {
+		now: "ahora", past: "hace %v", future: "dentro de %v",
+		units: [6]unitForms{
+			{plural.One: "segundo", plural.Other: "segundos"},
+			{plural.One: "minuto", plural.Other: "minutos"},
+			{plural.One: "hora", plural.Other: "horas"},
+			{plural.One: "día", plural.Other: "días"},
+			{plural.One: "mes", plural.Other: "meses"},
+			{plural.One: "año", plural.Other: "años"},
+		},
+	},
+	"it": {
+		now: "ora", past: "%v fa", future: "tra %v",
+		units: [6]unitForms{
+			{plural.One: "secondo", plural.Other: "secondi"},
+			{plural.One: "minuto", plural.Other: "minuti"},
+			{plural.One: "ora", plural.Other: "ore"},
+			{plural.One: "giorno", plural.Other: "giorni"},
+			{plural.One: "mese", plural.Other: "mesi"},
+			{plural.One: "anno", plural.Other: "anni"},
+		},
+	},
+	"pt": {
+		now: "agora", past: "há %v", future: "em %v",
+		units: [6]unitForms{
+			{plural.One: "segundo", plural.Other: "segundos"},
+			{plural.One: "minuto", plural.Other: "minutos"},
+			{plural.One: "hora", plural.Other: "horas"},
+			{plural.One: "dia", plural.Other: "dias"},
+			{plural.One: "mês", plural.Other: "meses"},
+			{plural.One: "ano", plural.Other: "anos"},
+		},
+	},
+	"nl": {
+		now: "nu", past: "%v geleden", future: "over %v",
+		units: [6]unitForms{
+			{plural.One: "seconde", plural.Other: "seconden"},
+			{plural.One: "minuut", plural.Other: "minuten"},
+			{plural.Other: "uur"},
+			{plural.One: "dag", plural.Other: "dagen"},
+			{plural.One: "maand", plural.Other: "maanden"},
+			{plural.Other: "jaar"},
+		},
+	},
+	"ru": {
+		now: "сейчас", past: "%v назад", future: "через %v",
+		units: [6]unitForms{
+			{plural.One: "секунду", plural.Few: "секунды", plural.Many: "секунд", plural.Other: "секунды"},
+			{plural.One: "минуту", plural.Few: "минуты", plural.Many: "минут", plural.Other: "минуты"},
+			{plural.One: "час", plural.Few: "часа", plural.Many: "часов", plural.Other: "часа"},
+			{plural.One: "день", plural.Few: "дня", plural.Many: "дней", plural.Other: "дня"},
+			{plural.One: "месяц", plural.Few: "месяца", plural.Many: "месяцев", plural.Other: "месяца"},
+			{plural.One: "год", plural.Few: "года", plural.Many: "лет", plural.Other: "года"},
+		},
+	},
+	"uk": {
+		now: "зараз", past: "%v тому", future: "через %v",
+		units: [6]unitForms{
+			{plural.One: "секунду", plural.Few: "секунди", plural.Many: "секунд", plural.Other: "секунди"},
+			{plural.One: "хвилину", plural.Few: "хвилини", plural.Many: "хвилин", plural.Other: "хвилини"},
+			{plural.One: "годину", plural.Few: "години", plural.Many: "годин", plural.Other: "години"},
+			{plural.One: "день", plural.Few: "дні", plural.Many: "днів", plural.Other: "дня"},
+			{plural.One: "місяць", plural.Few: "місяці", plural.Many: "місяців", plural.Other: "місяця"},
+			{plural.One: "рік", plural.Few: "роки", plural.Many: "років", plural.Other: "року"},
+		},
+	},
+	"pl": {
+		now: "teraz", past: "%v temu", future: "za %v",
+		units: [6]unitForms{
+			{plural.One: "sekundę", plural.Few: "sekundy", plural.Many: "sekund", plural.Other: "sekundy"},
+			{plural.One: "minutę", plural.Few: "minuty", plural.Many: "minut", plural.Other: "minuty"},
+			{plural.One: "godzinę", plural.Few: "godziny", plural.Many: "godzin", plural.Other: "godziny"},
+			{plural.One: "dzień", plural.Few: "dni", plural.Many: "dni", plural.Other: "dnia"},
+			{plural.One: "miesiąc", plural.Few: "miesiące", plural.Many: "miesięcy", plural.Other: "miesiąca"},
+			{plural.One: "rok", plural.Few: "lata", plural.Many: "lat", plural.Other: "roku"},
+		},
+	},
+	"cs": {
+		now: "nyní", past: "před %v", future: "za %v",
+		units: [6]unitForms{
+			{plural.One: "sekundu", plural.Few: "sekundy", plural.Many: "sekundy", plural.Other: "sekund"},
+			{plural.One: "minutu", plural.Few: "minuty", plural.Many: "minuty", plural.Other: "minut"},
+			{plural.One: "hodinu", plural.Few: "hodiny", plural.Many: "hodiny", plural.Other: "hodin"},
+			{plural.One: "den", plural.Few: "dny", plural.Many: "dne", plural.Other: "dní"},
+			{plural.One: "měsíc", plural.Few: "měsíce", plural.Many: "měsíce", plural.Other: "měsíců"},
+			{plural.One: "rok", plural.Few: "roky", plural.Many: "roku", plural.Other: "let"},
+		},
+		pastUnits: [6]unitForms{
+			{plural.One: "sekundou", plural.Many: "sekundy", plural.Other: "sekundami"},
+			{plural.One: "minutou", plural.Many: "minuty", plural.Other: "minutami"},
+			{plural.One: "hodinou", plural.Many: "hodiny", plural.Other: "hodinami"},
+			{plural.One: "dnem", plural.Many: "dne", plural.Other: "dny"},
+			{plural.One: "měsícem", plural.Many: "měsíce", plural.Other: "měsíci"},
+			{plural.One: "rokem", plural.Many: "roku", plural.Other: "lety"},
+		},
+	},
+}
+
+// Upper bounds of units in seconds, the last unit is unbounded
+var relativeTimeUnitSeconds = [6]float64{60, 60 * 60, 24 * 60 * 60, 30 * 24 * 60 * 60, 365 * 24 * 60 * 60, math.Inf(1)}
+var relativeTimeUnitSizes = [6]float64{1, 60, 60 * 60, 24 * 60 * 60, 30 * 24 * 60 * 60, 365 * 24 * 60 * 60}
+
+// RelativeTime formats time relative to now, e.g. "3 days ago" or "in 2 hours".
+// Words are chosen by CLDR plural rules of language, e.g. "2 дня" and "5 дней" in Russian.
+// Languages without own words fall back to English.
+func RelativeTime(v any, now time.Time, tag language.Tag) (string, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return "", err
+	}
+
+	base, _ := tag.Base()
+	words, has := relativeTimeLanguages[base.String()]
+	if !has {
+		words = relativeTimeLanguages["en"]
+	}
+
+	diff := t.Sub(now).Seconds()
+	seconds := math.Abs(diff)
+	if seconds < 1 {
+		return words.now, nil
+	}
+
+	unit := 0
+	for seconds >= relativeTimeUnitSeconds[unit] {
+		unit++
+	}
+
+	count := int(math.Floor(seconds / relativeTimeUnitSizes[unit]))
+	form := plural.Cardinal.MatchPlural(tag, count, 0, 0, 0, 0)
+
+	pattern, units := words.future, words.units
+	if diff < 0 {
+		pattern = words.past
+		if words.pastUnits[0] != nil {
+			units = words.pastUnits
+		}
+	}
+
+	amount := message.NewPrinter(tag).Sprintf("%d %v", count, units[unit].get(form))
+	return fmt.Sprintf(pattern, amount), nil
+}
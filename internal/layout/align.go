@@ -0,0 +1,99 @@
+package layout
+
+import (
+	"math"
+)
+
+// getAlign returns cross axis alignment of child in row, its own alignSelf takes precedence over align of parent
+func getAlign(props *CalculatedProperties, cn *Node) string {
+	if cn.Props.AlignSelf != "" && cn.Props.AlignSelf != "auto" {
+		return cn.Props.AlignSelf
+	}
+	return props.ChildAlign
+}
+
+// getMinRowHeight returns height of the only row of children, that is inner height of parent
+// if it is set, so children are aligned within whole parent. Otherwise, rows are as high as their children.
+func getMinRowHeight(nodes Nodes, level int, from int, props *CalculatedProperties, innerHeight float64) float64 {
+	if props.Size.H == -1 {
+		return 0
+	}
+
+	rowsCount := 0
+	nodes.IterateRows(level, from, func(_ int, _ *Node) {
+		rowsCount++
+	})
+	if rowsCount != 1 {
+		return 0
+	}
+
+	return innerHeight
+}
+
+// getRowHeightAndBaseline returns height of row and baseline of children aligned by baseline.
+// Row can be higher than its highest child, if children with different baselines are aligned.
+func getRowHeightAndBaseline(nodes Nodes, level int, from int, rowIndex int, props *CalculatedProperties, minHeight float64) (height float64, baseline float64) {
+	height = minHeight
+	nodes.IterateRow(level, from, rowIndex, func(cn *Node) {
+		if cn.IsAbsolutePositioned() {
+			return
+		}
+		if getAlign(props, cn) == "baseline" {
+			baseline = math.Max(baseline, cn.OuterBaseline())
+		}
+		height = math.Max(height, cn.OuterSize().H)
+	})
+
+	nodes.IterateRow(level, from, rowIndex, func(cn *Node) {
+		if !cn.IsAbsolutePositioned() && getAlign(props, cn) == "baseline" {
+			height = math.Max(height, baseline-cn.OuterBaseline()+cn.OuterSize().H)
+		}
+	})
+
+	return height, baseline
+}
+
+// getAlignOffset returns vertical offset of child with its margins inside its row
+func getAlignOffset(align string, cn *Node, rowHeight float64, rowBaseline float64) float64 {
+	switch align {
+	case "center":
+		return rowHeight/2 - cn.OuterSize().H/2
+	case "end":
+		return rowHeight - cn.OuterSize().H
+	case "baseline":
+		return rowBaseline - cn.OuterBaseline()
+	}
+	return 0
+}
+
+// getStretchSizes returns heights of children that are stretched to height of their rows in order of layout,
+// or nil if nothing is changed. Only children with height calculated from content are stretched.
+func getStretchSizes(nodes Nodes, level int, from int, props *CalculatedProperties, innerHeight float64) []float64 {
+	changed := make(map[*Node]float64)
+	minRowHeight := getMinRowHeight(nodes, level, from, props, innerHeight)
+
+	nodes.IterateRows(level, from, func(rowIndex int, _ *Node) {
+		rowHeight, _ := getRowHeightAndBaseline(nodes, level, from, rowIndex, props, minRowHeight)
+		nodes.IterateRow(level, from, rowIndex, func(cn *Node) {
+			height := rowHeight - cn.Props.Margin.Top() - cn.Props.Margin.Bottom()
+			if !cn.IsAbsolutePositioned() && cn.HasAutoHeight && cn.Size.H != height && getAlign(props, cn) == "stretch" {
+				changed[cn] = height
+			}
+		})
+	})
+
+	return sizesInLayoutOrder(nodes, level, from, changed)
+}
+
+// getBaseline returns baseline of node, that is baseline of its first child, or bottom of node if there are no children
+func getBaseline(nodes Nodes, level int, from int, props *CalculatedProperties) float64 {
+	baseline := props.Size.H
+	isFound := false
+	nodes.IterateChildNodes(level, from, func(cn *Node) {
+		if !isFound && !cn.IsAbsolutePositioned() {
+			baseline = props.Padding.Top() + cn.Pos.Top + cn.Baseline
+			isFound = true
+		}
+	})
+	return baseline
+}
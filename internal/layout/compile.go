@@ -0,0 +1,479 @@
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/vm"
+	"github.com/godknowsiamgood/decorender/internal/formatting"
+	"github.com/godknowsiamgood/decorender/internal/parsing"
+	"github.com/godknowsiamgood/decorender/internal/translations"
+	"github.com/godknowsiamgood/decorender/internal/utils"
+	"github.com/samber/lo"
+	"golang.org/x/exp/slices"
+	"golang.org/x/text/language"
+)
+
+// Template is a tree of compiled nodes ready for layout.
+// It is read only after compilation, so it can be used by concurrent renders.
+type Template struct {
+	root     compiledNode
+	scale    float64
+	locale   language.Tag
+	warnings parsing.ValidationErrors
+}
+
+// Warnings are problems of template that don't prevent rendering, e.g. missing translations
+func (t *Template) Warnings() parsing.ValidationErrors {
+	return t.warnings
+}
+
+// property is a value of node field. Constant values are parsed once at compile time,
+// templated values are evaluated and parsed at every render.
+type property[T any] struct {
+	isSet   bool
+	program *vm.Program
+	value   T
+	parse   func(v string) (T, error)
+}
+
+func (p *property[T]) get(ec *evalContext) (T, error) {
+	if p.program == nil {
+		return p.value, nil
+	}
+
+	result, err := ec.run(p.program)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return p.parse(stringify(result))
+}
+
+// lookup returns value of property, ok is false if property is not set or its templated value is malformed
+func (p *property[T]) lookup(ec *evalContext) (value T, ok bool) {
+	if !p.isSet {
+		return value, false
+	}
+	value, err := p.get(ec)
+	return value, err == nil
+}
+
+// getOr returns value of property or fallback if property is not set or its templated value is malformed
+func (p *property[T]) getOr(ec *evalContext, fallback T) T {
+	if value, ok := p.lookup(ec); ok {
+		return value
+	}
+	return fallback
+}
+
+// condition is a property that is evaluated to boolean value
+type condition struct {
+	isSet   bool
+	program *vm.Program
+	value   bool
+}
+
+func (c *condition) get(ec *evalContext) (bool, error) {
+	if c.program == nil {
+		return c.value, nil
+	}
+
+	result, err := ec.run(c.program)
+	if err != nil {
+		return false, err
+	}
+
+	return isTruthy(result), nil
+}
+
+// compiledNode is parsing.Node with all fields compiled into properties
+type compiledNode struct {
+	id string
+
+	forEach  property[string]
+	ifCond   condition
+	elseCond condition
+	text     property[string]
+	image    property[string]
+
+	size         property[unitValues]
+	width        property[unitValues]
+	height       property[unitValues]
+	aspectRatio  property[float64]
+	minWidth     property[unitValues]
+	maxWidth     property[unitValues]
+	minHeight    property[unitValues]
+	maxHeight    property[unitValues]
+	lineHeight   property[unitValues]
+	padding      property[unitValues]
+	margin       property[unitValues]
+	borderRadius property[unitValues]
+	innerGap     property[unitValues]
+	rotation     property[unitValues]
+	grow         property[float64]
+	shrink       property[float64]
+	fontSize     property[fontSizeValue]
+	minFontSize  property[unitValues]
+	maxFontSize  property[unitValues]
+	fontWeight   property[unitValues]
+
+	bkgColor  property[color.RGBA]
+	fontColor property[color.RGBA]
+	color     property[color.RGBA]
+
+	border   property[utils.Border]
+	absolute property[utils.AbsolutePosition]
+	offset   property[utils.AbsolutePosition]
+
+	innerDirection   property[string]
+	justify          property[string]
+	innerColumnAlign property[string]
+	align            property[string]
+	alignSelf        property[string]
+	innerWrap        property[string]
+	textAlign        property[string]
+	textTransform    property[string]
+	letterSpacing    property[letterSpacing]
+	textDecoration   property[utils.TextDecoration]
+	textStroke       property[utils.TextStroke]
+	textShadow       property[utils.TextShadow]
+	maxLines         property[int]
+	ellipsis         property[string]
+	gridColumns      property[[]GridTrack]
+	gridGap          property[unitValues]
+	columnSpan       property[int]
+	rowSpan          property[int]
+	bkgImageSize     property[string]
+	overflow         property[string]
+
+	font       property[fontShorthand]
+	fontFamily property[string]
+	fontStyle  property[string]
+
+	fontFeatures property[string]
+
+	params []compiledParam
+
+	inner []compiledNode
+	spans []compiledNode
+}
+
+// compiledParam is templated param of component. It is evaluated in context of node that uses component,
+// and is visible to expressions of node and all its children.
+type compiledParam struct {
+	name    string
+	program *vm.Program
+}
+
+// CompileOptions are options of template compilation
+type CompileOptions struct {
+	// DataType is optional type of user data. If it is set, expressions are type-checked against it.
+	DataType reflect.Type
+	// Functions are available in expressions
+	Functions Functions
+	// Locale is default locale of formatting functions
+	Locale language.Tag
+	// Translations are used by t function, Language is language of catalog to take texts from,
+	// and FallbackLanguage is used for keys that are missing in Language catalog.
+	Translations     *translations.Catalogs
+	Language         string
+	FallbackLanguage string
+}
+
+// Compile prepares template for layout: parses all constant values once and compiles all expressions,
+// so broken templates are reported before first render.
+func Compile(root parsing.Node, opts CompileOptions) (*Template, parsing.ValidationErrors) {
+	locale := opts.Locale
+	if locale == language.Und {
+		locale = formatting.DefaultLocale
+	}
+
+	exprOptions := builtinFunctions(locale)
+	exprOptions = append(exprOptions, translationFunctions(opts.Translations, opts.Language, opts.FallbackLanguage, locale)...)
+	// Custom functions go last, so they can override built-in ones
+	exprOptions = append(exprOptions, opts.Functions...)
+
+	c := compiler{
+		typeCheck:    opts.DataType != nil,
+		exprOptions:  exprOptions,
+		translations: opts.Translations,
+	}
+
+	t := &Template{
+		root:   c.compileNode(root, exprScope{value: opts.DataType}),
+		scale:  root.GetScale(),
+		locale: locale,
+	}
+	t.warnings = c.warnings.Normalize()
+
+	return t, c.errs.Normalize()
+}
+
+type compiler struct {
+	typeCheck bool
+	// box is content box of node being compiled, used to check that fitted font size can be calculated
+	box          fitBox
+	exprOptions  []expr.Option
+	translations *translations.Catalogs
+	errs         parsing.ValidationErrors
+	warnings     parsing.ValidationErrors
+}
+
+type nodeCompiler struct {
+	*compiler
+	n         parsing.Node
+	scope     exprScope
+	nodeScope exprScope
+}
+
+func (nc *nodeCompiler) addErr(name string, err error) {
+	nc.errs = append(nc.errs, &parsing.ValidationError{
+		Position: nc.n.FieldPositions[name],
+		Field:    name,
+		Err:      err,
+	})
+}
+
+// validate checks not templated value of field
+func (nc *nodeCompiler) validate(name string, str string) {
+	if validator := fieldValidators[name]; validator != nil {
+		if err := validator(str); err != nil {
+			nc.addErr(name, err)
+		}
+	}
+}
+
+// compileExpression compiles templated value of field in its scope
+func (nc *nodeCompiler) compileExpression(name string, str string) *vm.Program {
+	scope := nc.nodeScope
+	if name == "forEach" || name == "else" {
+		// forEach and else are evaluated in context of parent node, all other fields in context of current iteration
+		scope = nc.scope
+	}
+
+	program, err := compileExpression(str, scope, nc.typeCheck, nc.exprOptions)
+	if err != nil {
+		nc.addErr(name, err)
+		return nil
+	}
+
+	if nc.translations != nil {
+		for _, key := range getTranslationKeys(program) {
+			if missing := nc.translations.MissingLanguages(key); len(missing) > 0 {
+				nc.warnings = append(nc.warnings, &parsing.ValidationError{
+					Position: nc.n.FieldPositions[name],
+					Field:    name,
+					Err:      fmt.Errorf("missing translation of \"%v\" for %v", key, strings.Join(missing, ", ")),
+				})
+			}
+		}
+	}
+
+	return program
+}
+
+func compileProperty[T any](nc *nodeCompiler, name string, str string, parse func(v string) (T, error)) property[T] {
+	p := property[T]{
+		isSet: str != "",
+		parse: parse,
+	}
+
+	if strings.HasPrefix(str, "~") {
+		p.program = nc.compileExpression(name, str)
+		return p
+	}
+
+	if p.isSet {
+		nc.validate(name, str)
+	}
+
+	// Value of not set property is still parsed, so enum properties get their default value
+	p.value, _ = parse(str)
+
+	return p
+}
+
+func compileCondition(nc *nodeCompiler, name string, str string) condition {
+	c := condition{
+		isSet: str != "",
+	}
+
+	if !c.isSet {
+		return c
+	}
+
+	if strings.HasPrefix(str, "~") {
+		c.program = nc.compileExpression(name, str)
+		return c
+	}
+
+	nc.validate(name, str)
+
+	c.value = str != "false"
+
+	return c
+}
+
+func (c *compiler) compileNode(n parsing.Node, scope exprScope) compiledNode {
+	nc := nodeCompiler{
+		compiler: c,
+		n:        n,
+		scope:    scope,
+	}
+
+	var err error
+	nc.nodeScope, err = scope.forEachScope(n.ForEach)
+	if err != nil && c.typeCheck {
+		nc.addErr("forEach", err)
+	}
+
+	if n.Scale != "" {
+		nc.validate("scale", n.Scale)
+	}
+
+	// Params are evaluated in current iteration, before any other field
+	var params []compiledParam
+	if len(n.BoundParams) > 0 {
+		names := lo.Keys(n.BoundParams)
+		sort.Strings(names)
+		for _, name := range names {
+			if program := nc.compileExpression("props", n.BoundParams[name]); program != nil {
+				params = append(params, compiledParam{name: name, program: program})
+			}
+		}
+		nc.nodeScope = nc.nodeScope.withParams(names)
+	}
+
+	cn := compiledNode{
+		id: n.Id,
+
+		forEach:  compileProperty(&nc, "forEach", n.ForEach, parseString),
+		ifCond:   compileCondition(&nc, "if", n.If),
+		elseCond: compileCondition(&nc, "else", n.Else),
+		text:     compileProperty(&nc, "text", n.Text, parseString),
+		image:    compileProperty(&nc, "bkgImage", n.Image, parseString),
+
+		size:         compileProperty(&nc, "size", n.Size, unitValuesParser(2, false)),
+		width:        compileProperty(&nc, "width", n.Width, unitValuesParser(1, false)),
+		height:       compileProperty(&nc, "height", n.Height, unitValuesParser(1, false)),
+		aspectRatio:  compileProperty(&nc, "aspectRatio", n.AspectRatio, parseAspectRatio),
+		minWidth:     compileProperty(&nc, "minWidth", n.MinWidth, unitValuesParser(1, false)),
+		maxWidth:     compileProperty(&nc, "maxWidth", n.MaxWidth, unitValuesParser(1, false)),
+		minHeight:    compileProperty(&nc, "minHeight", n.MinHeight, unitValuesParser(1, false)),
+		maxHeight:    compileProperty(&nc, "maxHeight", n.MaxHeight, unitValuesParser(1, false)),
+		lineHeight:   compileProperty(&nc, "lineHeight", n.LineHeight, unitValuesParser(1, false)),
+		padding:      compileProperty(&nc, "padding", n.Padding, unitValuesParser(4, false)),
+		margin:       compileProperty(&nc, "margin", n.Margin, unitValuesParser(4, true)),
+		borderRadius: compileProperty(&nc, "borderRadius", n.BorderRadius, unitValuesParser(4, false)),
+		innerGap:     compileProperty(&nc, "innerGap", n.InnerGap, unitValuesParser(1, false)),
+		rotation:     compileProperty(&nc, "rotate", n.Rotation, unitValuesParser(1, true)),
+		grow:         compileProperty(&nc, "grow", n.Grow, parseFactor),
+		shrink:       compileProperty(&nc, "shrink", n.Shrink, parseFactor),
+		fontSize:     compileProperty(&nc, "fontSize", n.FontSize, parseFontSize),
+		minFontSize:  compileProperty(&nc, "minFontSize", n.MinFontSize, unitValuesParser(1, false)),
+		maxFontSize:  compileProperty(&nc, "maxFontSize", n.MaxFontSize, unitValuesParser(1, false)),
+		fontWeight:   compileProperty(&nc, "fontWeight", n.FontWeight, unitValuesParser(1, false)),
+
+		bkgColor:  compileProperty(&nc, "bkgColor", n.BkgColor, parseColor),
+		fontColor: compileProperty(&nc, "fontColor", n.FontColor, parseColor),
+		color:     compileProperty(&nc, "color", n.Color, parseColor),
+
+		border:   compileProperty(&nc, "border", n.Border, parseBorderProperty),
+		absolute: compileProperty(&nc, "absolute", n.Absolute, parseAnchors),
+		offset:   compileProperty(&nc, "offset", n.Offset, parseAnchors),
+
+		innerDirection:   compileProperty(&nc, "innerDirection", n.InnerDirection, enumParser(innerDirectionValues)),
+		justify:          compileProperty(&nc, "justify", n.Justify, enumParser(justifyValues)),
+		innerColumnAlign: compileProperty(&nc, "innerColumnAlign", n.ChildrenColumnAlign, enumParser(innerColumnAlignValues)),
+		align:            compileProperty(&nc, "align", n.Align, enumParser(alignValues)),
+		alignSelf:        compileProperty(&nc, "alignSelf", n.AlignSelf, enumParser(alignSelfValues)),
+		innerWrap:        compileProperty(&nc, "innerWrap", n.ChildrenWrap, enumParser(innerWrapValues)),
+		textAlign:        compileProperty(&nc, "textAlign", n.TextAlign, enumParser(textAlignValues)),
+		textTransform:    compileProperty(&nc, "textTransform", n.TextTransform, enumParser(textTransformValues)),
+		letterSpacing:    compileProperty(&nc, "letterSpacing", n.LetterSpacing, parseLetterSpacing),
+		textDecoration:   compileProperty(&nc, "textDecoration", n.TextDecoration, parseTextDecoration),
+		textStroke:       compileProperty(&nc, "textStroke", n.TextStroke, parseTextStroke),
+		textShadow:       compileProperty(&nc, "textShadow", n.TextShadow, parseTextShadow),
+		maxLines:         compileProperty(&nc, "maxLines", n.MaxLines, parseMaxLines),
+		ellipsis:         compileProperty(&nc, "ellipsis", n.Ellipsis, parseString),
+		gridColumns:      compileProperty(&nc, "gridColumns", n.GridColumns, parseGridTracks),
+		gridGap:          compileProperty(&nc, "gridGap", n.GridGap, unitValuesParser(2, false)),
+		columnSpan:       compileProperty(&nc, "columnSpan", n.ColumnSpan, parseSpan),
+		rowSpan:          compileProperty(&nc, "rowSpan", n.RowSpan, parseSpan),
+		bkgImageSize:     compileProperty(&nc, "bkgImageSize", n.BkgImageSize, enumParser(bkgImageSizeValues)),
+		overflow:         compileProperty(&nc, "overflow", n.Overflow, enumParser(overflowValues)),
+
+		font:       compileProperty(&nc, "font", n.Font, parseFontShorthand),
+		fontFamily: compileProperty(&nc, "fontFamily", n.FontFamily, parseString),
+		fontStyle:  compileProperty(&nc, "fontStyle", n.FontStyle, parseString),
+
+		fontFeatures: compileProperty(&nc, "fontFeatures", n.FontFeatures, parseFontFeatures),
+
+		params: params,
+	}
+
+	box := c.box.withNode(n)
+	isFit := strings.TrimSpace(n.FontSize) == "fit"
+	if isFit && n.Text != "" && !box.isBounded() {
+		nc.addErr("fontSize", errors.New("fit needs width and height of node or of one of its parents"))
+	}
+
+	if len(n.Inner) > 0 {
+		parentBox := c.box
+		c.box = box.forChildren(n)
+		cn.inner = make([]compiledNode, len(n.Inner))
+		for i, pn := range n.Inner {
+			cn.inner[i] = c.compileNode(pn, nc.nodeScope)
+		}
+		c.box = parentBox
+	}
+
+	if len(n.Spans) > 0 {
+		if n.Text != "" {
+			nc.addErr("text", errors.New("text can't be used together with spans"))
+		}
+		if isFit {
+			nc.addErr("fontSize", errors.New("fit can't be used together with spans"))
+		}
+		// Box is not checked for spans, fit in them is reported by validateSpan
+		parentBox := c.box
+		c.box = fitBox{w: true, h: true}
+		cn.spans = make([]compiledNode, len(n.Spans))
+		for i, sn := range n.Spans {
+			c.validateSpan(sn)
+			cn.spans[i] = c.compileNode(sn, nc.nodeScope)
+		}
+		c.box = parentBox
+	}
+
+	return cn
+}
+
+// validateSpan reports fields of span that don't affect text, they are checked after components
+// and includes are expanded, so fields that come from them are reported too
+func (c *compiler) validateSpan(n parsing.Node) {
+	nc := nodeCompiler{compiler: c, n: n}
+
+	parsing.IterateStringFields(&n, func(name string, field *string) bool {
+		if *field != "" && !slices.Contains(spanFields, name) {
+			nc.addErr(name, errors.New("field can't be used in span"))
+		}
+		return true
+	})
+	if strings.TrimSpace(n.FontSize) == "fit" {
+		nc.addErr("fontSize", errors.New("fit can't be used in span"))
+	}
+	if len(n.Inner) > 0 {
+		nc.addErr("inner", errors.New("field can't be used in span"))
+	}
+	if len(n.Spans) > 0 {
+		nc.addErr("spans", errors.New("field can't be used in span"))
+	}
+}
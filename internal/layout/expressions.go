@@ -0,0 +1,170 @@
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/file"
+	"github.com/antonmedv/expr/vm"
+	"golang.org/x/exp/slices"
+)
+
+var anyType = reflect.TypeOf((*any)(nil)).Elem()
+
+// exprScope describes types of expressions environment for particular node.
+// nil types are unknown.
+type exprScope struct {
+	value  reflect.Type
+	parent reflect.Type
+	inLoop bool
+	// params are names of templated component params, their types are not known
+	params []string
+}
+
+// env returns zero value to check expressions against, or nil if types are not known
+func (s exprScope) env() any {
+	var fields []reflect.StructField
+
+	if !s.inLoop {
+		t := s.value
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			// Only structs fields are known before render
+			return nil
+		}
+		if len(s.params) == 0 {
+			return reflect.Zero(t).Interface()
+		}
+
+		// Same fields as map passed to expressions with params, see evalContext.getEnv
+		for _, f := range reflect.VisibleFields(t) {
+			if f.IsExported() && !f.Anonymous && !slices.Contains(s.params, exprFieldName(f)) {
+				fields = append(fields, reflect.StructField{Name: f.Name, Type: f.Type, Tag: f.Tag})
+			}
+		}
+	} else {
+		// Same fields as map passed to expressions in loops
+		fields = []reflect.StructField{
+			{Name: "Value", Type: typeOrAny(s.value), Tag: `expr:"value"`},
+			{Name: "Parent", Type: typeOrAny(s.parent), Tag: `expr:"parent"`},
+			{Name: "Index", Type: reflect.TypeOf(0), Tag: `expr:"index"`},
+		}
+	}
+
+	for i, name := range s.params {
+		fields = append(fields, reflect.StructField{
+			Name: "Param" + strconv.Itoa(i),
+			Type: anyType,
+			Tag:  reflect.StructTag(`expr:"` + name + `"`),
+		})
+	}
+
+	return reflect.Zero(reflect.StructOf(fields)).Interface()
+}
+
+// withParams returns scope with names of component params added
+func (s exprScope) withParams(names []string) exprScope {
+	params := make([]string, 0, len(s.params)+len(names))
+	for _, name := range s.params {
+		if !slices.Contains(names, name) {
+			params = append(params, name)
+		}
+	}
+	s.params = append(params, names...)
+	return s
+}
+
+// forEachScope returns scope for node with forEach, same way as RunForEach iterates values
+func (s exprScope) forEachScope(forEach string) (exprScope, error) {
+	if forEach == "" {
+		return s, nil
+	}
+
+	scope, err := s.loopScope(forEach)
+	// Params of components are visible in loops too
+	scope.params = s.params
+	return scope, err
+}
+
+func (s exprScope) loopScope(forEach string) (exprScope, error) {
+	if _, err := strconv.Atoi(forEach); err == nil {
+		return exprScope{value: reflect.TypeOf(0), parent: s.value, inLoop: true}, nil
+	}
+
+	t := s.value
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || strings.HasPrefix(forEach, "~") {
+		return exprScope{inLoop: true}, nil
+	}
+
+	var fieldType reflect.Type
+	switch t.Kind() {
+	case reflect.Struct:
+		f, has := t.FieldByName(forEach)
+		if !has {
+			return exprScope{inLoop: true}, fmt.Errorf("field %v not found in type %v", forEach, t)
+		}
+		fieldType = f.Type
+	case reflect.Map:
+		fieldType = t.Elem()
+	}
+
+	if fieldType != nil && fieldType.Kind() == reflect.Slice {
+		return exprScope{value: fieldType.Elem(), parent: fieldType, inLoop: true}, nil
+	}
+
+	return exprScope{inLoop: true}, nil
+}
+
+// exprFieldName returns name of struct field in expressions
+func exprFieldName(f reflect.StructField) string {
+	if name := f.Tag.Get("expr"); name != "" {
+		return name
+	}
+	return f.Name
+}
+
+func typeOrAny(t reflect.Type) reflect.Type {
+	if t == nil {
+		return anyType
+	}
+	return t
+}
+
+// compileExpression compiles templated value of field, and optionally checks it against types of scope
+func compileExpression(str string, scope exprScope, typeCheck bool, options []expr.Option) (*vm.Program, error) {
+	src := strings.TrimLeft(str, "~")
+
+	program, err := expr.Compile(src, options...)
+	if err != nil {
+		return nil, formatExpressionError(src, err)
+	}
+
+	if typeCheck {
+		if env := scope.env(); env != nil {
+			if _, err = expr.Compile(src, append([]expr.Option{expr.Env(env)}, options...)...); err != nil {
+				return nil, formatExpressionError(src, err)
+			}
+		}
+	}
+
+	return program, nil
+}
+
+// formatExpressionError keeps error in one line, without source snippet
+func formatExpressionError(src string, err error) error {
+	var fileErr *file.Error
+	if errors.As(err, &fileErr) {
+		return fmt.Errorf("expression \"%v\": %v", strings.TrimSpace(src), strings.TrimSpace(fileErr.Message))
+	}
+	return fmt.Errorf("expression \"%v\": %w", strings.TrimSpace(src), err)
+}
@@ -0,0 +1,183 @@
+package layout
+
+import (
+	"fmt"
+	"github.com/bluele/gcache"
+	"github.com/godknowsiamgood/decorender/internal/fonts"
+	"github.com/godknowsiamgood/decorender/internal/parsing"
+	"github.com/godknowsiamgood/decorender/internal/utils"
+	"golang.org/x/image/font/opentype"
+	"math"
+	"strings"
+)
+
+// fontSizeValue is parsed fontSize property, that is either size or fit keyword
+type fontSizeValue struct {
+	isFit bool
+	size  unitValues
+}
+
+func parseFontSize(value string) (fontSizeValue, error) {
+	if strings.TrimSpace(value) == "fit" {
+		return fontSizeValue{isFit: true}, nil
+	}
+	size, err := parseUnitValues(value, 1, false)
+	if err != nil {
+		return fontSizeValue{}, fmt.Errorf("malformed font size \"%v\", expected number or fit", value)
+	}
+	return fontSizeValue{size: size}, nil
+}
+
+// fitBox tracks if content box of node is bounded, so font size can be fitted to it. Box is content size
+// of node, or of the nearest parent that has size. Sizes forced by parent (grid cells, stretching, grow)
+// or by absolute anchors are known only at layout, so such nodes are considered bounded.
+type fitBox struct {
+	w, h bool
+}
+
+func (b fitBox) withNode(n parsing.Node) fitBox {
+	if n.Absolute != "" || n.Grow != "" || n.AlignSelf != "" || n.AspectRatio != "" {
+		return fitBox{w: true, h: true}
+	}
+	b.w = b.w || n.Size != "" || n.Width != "" || n.MaxWidth != ""
+	b.h = b.h || n.Size != "" || n.Height != "" || n.MaxHeight != ""
+	return b
+}
+
+func (b fitBox) forChildren(n parsing.Node) fitBox {
+	if n.Align != "" || n.InnerDirection == "grid" || strings.HasPrefix(n.InnerDirection, "~") {
+		return fitBox{w: true, h: true}
+	}
+	return b
+}
+
+func (b fitBox) isBounded() bool {
+	return b.w && b.h
+}
+
+// fitFontSizeStep is precision of fitted font size
+const fitFontSizeStep = 0.5
+
+// fitFontSizeKey is everything that fitted font size depends on
+type fitFontSizeKey struct {
+	text string
+	// font is loaded font that face description matches, not family, as it may match other font
+	// when more faces are loaded
+	font          *opentype.Font
+	features      string
+	language      string
+	letterSpacing float64
+	spacingEm     float64
+	box           utils.Size
+	lineHeight    float64
+	minFontSize   float64
+	maxFontSize   float64
+	maxLines      int
+	isWrapping    bool
+	gap           float64
+}
+
+var fitFontSizes = gcache.New(1000).LRU().Build()
+
+// getFitFontSize returns the largest font size between min and max font sizes, at which wrapped text fits box.
+// If text does not fit even with min font size, min font size is returned.
+func getFitFontSize(text string, props CalculatedProperties, box utils.Size) float64 {
+	// Font is nil if family is not loaded, then text is not measured at all
+	font, _ := fonts.GetFont(props.FontDescription)
+
+	key := fitFontSizeKey{
+		text:          text,
+		font:          font,
+		features:      props.FontDescription.Features,
+		language:      props.FontDescription.Language,
+		letterSpacing: props.FontDescription.LetterSpacing,
+		spacingEm:     props.LetterSpacingEm,
+		box:           box,
+		lineHeight:    props.LineHeight,
+		minFontSize:   props.MinFontSize,
+		maxFontSize:   props.MaxFontSize,
+		maxLines:      props.MaxLines,
+		isWrapping:    props.IsWrappingEnabled,
+		gap:           props.InnerGap,
+	}
+	if props.LetterSpacingEm != 0 {
+		key.letterSpacing = 0
+	}
+
+	if v, err := fitFontSizes.Get(key); err == nil {
+		return v.(float64)
+	}
+
+	maxFontSize := props.MaxFontSize
+	if maxFontSize == -1 {
+		maxFontSize = box.H
+	}
+
+	// Binary search of steps, as text that fits with some size fits with all smaller sizes
+	tokens := splitText(text)
+	low := int(math.Ceil(props.MinFontSize / fitFontSizeStep))
+	high := int(math.Floor(maxFontSize / fitFontSizeStep))
+	for low < high {
+		mid := (low + high + 1) / 2
+		if isTextFitting(tokens, float64(mid)*fitFontSizeStep, props, box) {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+
+	size := math.Max(props.MinFontSize, float64(low)*fitFontSizeStep)
+	_ = fitFontSizes.Set(key, size)
+
+	return size
+}
+
+// isTextFitting wraps tokens of text the same way as layout does and checks if rows fit box
+func isTextFitting(tokens []string, fontSize float64, props CalculatedProperties, box utils.Size) bool {
+	fd := getFitFontDescription(props, fontSize)
+
+	lineHeight := props.LineHeight
+	if lineHeight == -1 {
+		lineHeight = fontSize * 1.2
+	}
+
+	whitespaceWidth := fonts.MeasureTextWidth(" ", fd)
+
+	rowsCount := 1
+	var currentWidth float64
+	for _, t := range tokens {
+		width := fonts.MeasureTextWidth(t, fd)
+		if width > box.W {
+			return false
+		}
+		if currentWidth+width > box.W {
+			if !props.IsWrappingEnabled {
+				return false
+			}
+			rowsCount++
+			currentWidth = 0
+		}
+		if strings.HasSuffix(t, hyphenString) {
+			currentWidth += width + props.InnerGap
+		} else {
+			currentWidth += width + whitespaceWidth + props.InnerGap
+		}
+	}
+
+	if props.MaxLines > 0 && rowsCount > props.MaxLines {
+		return false
+	}
+
+	return float64(rowsCount)*lineHeight <= box.H
+}
+
+// getFitFontDescription returns font description of text with fitted font size,
+// with letter spacing in em resolved for this size
+func getFitFontDescription(props CalculatedProperties, fontSize float64) fonts.FaceDescription {
+	fd := props.FontDescription
+	fd.Size = fontSize
+	if props.LetterSpacingEm != 0 {
+		fd.LetterSpacing = props.LetterSpacingEm * fontSize
+	}
+	return fd
+}
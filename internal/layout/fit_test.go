@@ -0,0 +1,72 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/godknowsiamgood/decorender/internal/fonts"
+	"github.com/godknowsiamgood/decorender/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFontSize(t *testing.T) {
+	v, err := parseFontSize("fit")
+	assert.NoError(t, err)
+	assert.True(t, v.isFit)
+
+	v, err = parseFontSize("12")
+	assert.NoError(t, err)
+	assert.False(t, v.isFit)
+	assert.Equal(t, 12.0, v.size.values[0])
+
+	_, err = parseFontSize("fits")
+	assert.Error(t, err)
+}
+
+func TestGetFitFontSize(t *testing.T) {
+	assert.NoError(t, fonts.LoadFaces(nil, nil))
+
+	props := CalculatedProperties{
+		FontDescription:   fonts.FaceDescription{Family: fonts.DefaultFamily, Size: 16, Weight: 400},
+		LineHeight:        -1,
+		IsWrappingEnabled: true,
+		MinFontSize:       1,
+		MaxFontSize:       -1,
+	}
+	box := utils.Size{W: 200, H: 100}
+	text := "Headline of some length"
+
+	size := getFitFontSize(text, props, box)
+	tokens := splitText(text)
+	assert.True(t, isTextFitting(tokens, size, props, box))
+	assert.False(t, isTextFitting(tokens, size+fitFontSizeStep, props, box))
+
+	// Cached value is the same
+	assert.Equal(t, size, getFitFontSize(text, props, box))
+
+	// Longer text is smaller
+	assert.Less(t, getFitFontSize(text+" and even longer", props, box), size)
+
+	// Limited rows make text smaller
+	props.MaxLines = 1
+	assert.Less(t, getFitFontSize(text, props, box), size)
+	props.MaxLines = 0
+
+	// Max and min limits
+	props.MaxFontSize = 10
+	assert.Equal(t, 10.0, getFitFontSize(text, props, box))
+	props.MaxFontSize = -1
+	props.MinFontSize = 300
+	assert.Equal(t, 300.0, getFitFontSize(text, props, box))
+	props.MinFontSize = 1
+
+	// Letter spacing in em is resolved for every tried size, not for the original one
+	word := "Headline"
+	box = utils.Size{W: 200, H: 50}
+	props.LetterSpacingEm = 0.3
+	props.FontDescription.LetterSpacing = 0.3 * props.FontDescription.Size
+	size = getFitFontSize(word, props, box)
+	fd := getFitFontDescription(props, size)
+	assert.Equal(t, 0.3*size, fd.LetterSpacing)
+	assert.LessOrEqual(t, fonts.MeasureTextWidth(word, fd), box.W)
+	assert.Greater(t, fonts.MeasureTextWidth(word, getFitFontDescription(props, size+fitFontSizeStep)), box.W)
+}
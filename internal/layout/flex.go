@@ -0,0 +1,119 @@
+package layout
+
+import (
+	"math"
+
+	"github.com/godknowsiamgood/decorender/internal/utils"
+)
+
+// forcedSizes are sizes of children that are forced by parent, e.g. with grow, shrink or stretch.
+// Children take their sizes in order of layout, -1 means that size is not changed.
+type forcedSizes struct {
+	widths  []float64
+	heights []float64
+	next    int
+}
+
+// take returns forced size of next child
+func (fs *forcedSizes) take() utils.Size {
+	size := utils.Size{W: -1, H: -1}
+	if fs.next < len(fs.widths) {
+		size.W = fs.widths[fs.next]
+	}
+	if fs.next < len(fs.heights) {
+		size.H = fs.heights[fs.next]
+	}
+	fs.next++
+	return size
+}
+
+// getFlexSizes distributes free space of every row among children proportionally to their grow factors,
+// or takes overflow from children proportionally to their shrink factors and sizes.
+// Result are new main sizes of children in order of layout, -1 for children that are not changed,
+// or nil if nothing is changed. Free space is known only if parent size is set along its direction.
+func getFlexSizes(nodes Nodes, level int, from int, props *CalculatedProperties, innerSize utils.Size, textWhitespaceWidth float64) []float64 {
+	if props.IsChildrenDirectionRow && props.Size.W == -1 || !props.IsChildrenDirectionRow && props.Size.H == -1 {
+		return nil
+	}
+
+	hasFactors := false
+	nodes.IterateChildNodes(level, from, func(cn *Node) {
+		hasFactors = hasFactors || !cn.IsAbsolutePositioned() && (cn.Props.Grow > 0 || cn.Props.Shrink > 0)
+	})
+	if !hasFactors {
+		return nil
+	}
+
+	changed := make(map[*Node]float64)
+
+	if props.IsChildrenDirectionRow {
+		nodes.IterateRows(level, from, func(rowIndex int, _ *Node) {
+			total, _ := nodes.RowTotalWidth(level, from, rowIndex, textWhitespaceWidth, props.InnerGap)
+			var row []*Node
+			nodes.IterateRow(level, from, rowIndex, func(cn *Node) {
+				row = append(row, cn)
+			})
+			distributeFreeSpace(row, innerSize.W-total, false, changed)
+		})
+	} else {
+		total, _ := nodes.RowsTotalHeight(level, from, props.InnerGap)
+		var column []*Node
+		nodes.IterateChildNodes(level, from, func(cn *Node) {
+			column = append(column, cn)
+		})
+		distributeFreeSpace(column, innerSize.H-total, true, changed)
+	}
+
+	return sizesInLayoutOrder(nodes, level, from, changed)
+}
+
+// sizesInLayoutOrder returns changed sizes of children in order of layout, -1 for children that are not changed,
+// or nil if nothing is changed
+func sizesInLayoutOrder(nodes Nodes, level int, from int, changed map[*Node]float64) []float64 {
+	if len(changed) == 0 {
+		return nil
+	}
+
+	var sizes []float64
+	for i := from; i < len(nodes); i++ {
+		if nodes[i].Level != level {
+			continue
+		}
+		if size, has := changed[&nodes[i]]; has {
+			sizes = append(sizes, size)
+		} else {
+			sizes = append(sizes, -1)
+		}
+	}
+
+	return sizes
+}
+
+func distributeFreeSpace(line []*Node, free float64, isVertical bool, changed map[*Node]float64) {
+	mainSize := func(cn *Node) float64 {
+		if isVertical {
+			return cn.Size.H
+		}
+		return cn.Size.W
+	}
+
+	var growSum, shrinkSum float64
+	for _, cn := range line {
+		if cn.IsAbsolutePositioned() {
+			continue
+		}
+		growSum += cn.Props.Grow
+		shrinkSum += cn.Props.Shrink * mainSize(cn)
+	}
+
+	for _, cn := range line {
+		if cn.IsAbsolutePositioned() {
+			continue
+		}
+		if free > 0 && growSum > 0 && cn.Props.Grow > 0 {
+			changed[cn] = mainSize(cn) + free*cn.Props.Grow/growSum
+		} else if free < 0 && shrinkSum > 0 && cn.Props.Shrink > 0 {
+			changed[cn] = math.Max(0, mainSize(cn)+free*cn.Props.Shrink*mainSize(cn)/shrinkSum)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package layout
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"time"
+
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/ast"
+	"github.com/antonmedv/expr/vm"
+	"github.com/godknowsiamgood/decorender/internal/formatting"
+	"github.com/godknowsiamgood/decorender/internal/translations"
+	"golang.org/x/text/language"
+)
+
+var functionNameRegex = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Functions are Go functions available in expressions
+type Functions []expr.Option
+
+// NewFunctions wraps Go functions to be called from expressions.
+// Each function must return one value and optionally an error.
+// Arguments are converted to parameter types when possible, e.g. int to float64.
+func NewFunctions(funcs map[string]any) (Functions, error) {
+	result := make(Functions, 0, 2*len(funcs))
+
+	for name, fn := range funcs {
+		option, err := wrapFunction(name, fn)
+		if err != nil {
+			return nil, err
+		}
+		// Functions take precedence over expr builtins with the same name, e.g. date
+		result = append(result, expr.DisableBuiltin(name), option)
+	}
+
+	return result, nil
+}
+
+// builtinFunctions are formatting functions available in all templates.
+// Locale is used when it is not passed to function explicitly.
+func builtinFunctions(locale language.Tag) Functions {
+	getLocale := func(args []string) (language.Tag, error) {
+		if len(args) == 0 || args[0] == "" {
+			return locale, nil
+		}
+		return formatting.ParseLocale(args[0])
+	}
+
+	funcs := map[string]any{
+		"number": func(v any, locale ...string) (string, error) {
+			tag, err := getLocale(locale)
+			if err != nil {
+				return "", err
+			}
+			return formatting.Number(v, tag)
+		},
+		"percent": func(v any, locale ...string) (string, error) {
+			tag, err := getLocale(locale)
+			if err != nil {
+				return "", err
+			}
+			return formatting.Percent(v, tag)
+		},
+		"currency": func(v any, code string, locale ...string) (string, error) {
+			tag, err := getLocale(locale)
+			if err != nil {
+				return "", err
+			}
+			return formatting.Currency(v, code, tag)
+		},
+		"date": func(v any, layoutAndLocale ...string) (string, error) {
+			var layout string
+			if len(layoutAndLocale) > 0 {
+				layout = layoutAndLocale[0]
+				layoutAndLocale = layoutAndLocale[1:]
+			}
+			tag, err := getLocale(layoutAndLocale)
+			if err != nil {
+				return "", err
+			}
+			return formatting.Date(v, layout, tag)
+		},
+		"relativeTime": func(v any, locale ...string) (string, error) {
+			tag, err := getLocale(locale)
+			if err != nil {
+				return "", err
+			}
+			return formatting.RelativeTime(v, time.Now(), tag)
+		},
+	}
+
+	functions, err := NewFunctions(funcs)
+	if err != nil {
+		panic(err)
+	}
+
+	return functions
+}
+
+// translationFunctions return t function, that takes text of key from catalog of language.
+// Without catalogs key itself is returned.
+func translationFunctions(catalogs *translations.Catalogs, lang string, fallbackLang string, locale language.Tag) Functions {
+	functions, err := NewFunctions(map[string]any{
+		"t": func(key string, args ...any) string {
+			return catalogs.Translate(lang, fallbackLang, locale, key, args...)
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return functions
+}
+
+// getTranslationKeys returns constant keys passed to t function in expression
+func getTranslationKeys(program *vm.Program) []string {
+	var v translationKeysVisitor
+	node := program.Node
+	ast.Walk(&node, &v)
+	return v.keys
+}
+
+type translationKeysVisitor struct {
+	keys []string
+}
+
+func (v *translationKeysVisitor) Visit(node *ast.Node) {
+	call, ok := (*node).(*ast.CallNode)
+	if !ok || len(call.Arguments) == 0 {
+		return
+	}
+	if callee, ok := call.Callee.(*ast.IdentifierNode); !ok || callee.Value != "t" {
+		return
+	}
+	if key, ok := call.Arguments[0].(*ast.StringNode); ok {
+		v.keys = append(v.keys, key.Value)
+	}
+}
+
+func wrapFunction(name string, fn any) (expr.Option, error) {
+	if !functionNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("function %v: invalid name", name)
+	}
+
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return nil, fmt.Errorf("function %v: expected func, got %T", name, fn)
+	}
+
+	ft := fv.Type()
+	if ft.NumOut() == 0 || ft.NumOut() > 2 || (ft.NumOut() == 2 && ft.Out(1) != errorType) {
+		return nil, fmt.Errorf("function %v: must return one value and optional error", name)
+	}
+
+	call := func(params ...any) (any, error) {
+		args, err := convertArguments(ft, params)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+
+		out := fv.Call(args)
+		if len(out) == 2 && !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+
+		return out[0].Interface(), nil
+	}
+
+	// Function itself is passed as its type, so arity and return type are checked at compile time.
+	// Arguments are checked only partly, e.g. float64 params accept any value and fail at render time.
+	return expr.Function(name, call, fn), nil
+}
+
+func convertArguments(ft reflect.Type, params []any) ([]reflect.Value, error) {
+	numIn := ft.NumIn()
+	if ft.IsVariadic() && len(params) < numIn-1 || !ft.IsVariadic() && len(params) != numIn {
+		return nil, fmt.Errorf("expected %v arguments, got %v", numIn, len(params))
+	}
+
+	args := make([]reflect.Value, len(params))
+	for i, p := range params {
+		var pt reflect.Type
+		if ft.IsVariadic() && i >= numIn-1 {
+			pt = ft.In(numIn - 1).Elem()
+		} else {
+			pt = ft.In(i)
+		}
+
+		if p == nil {
+			args[i] = reflect.Zero(pt)
+			continue
+		}
+
+		v := reflect.ValueOf(p)
+		switch {
+		case v.Type().AssignableTo(pt):
+			args[i] = v
+		case isNumberKind(v.Kind()) && isNumberKind(pt.Kind()), v.Kind() == pt.Kind() && v.Type().ConvertibleTo(pt):
+			args[i] = v.Convert(pt)
+		default:
+			return nil, fmt.Errorf("can't use %T as argument %v of type %v", p, i+1, pt)
+		}
+	}
+
+	return args, nil
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
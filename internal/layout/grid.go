@@ -0,0 +1,290 @@
+package layout
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	gridTrackAbs = iota
+	gridTrackPercent
+	gridTrackFraction
+)
+
+// GridTrack is width of grid column: absolute, in percents of grid width, or fraction of free space
+type GridTrack struct {
+	Value float64
+	Unit  int
+}
+
+// parseGridTracks parses widths of grid columns, e.g. "1fr 2fr 100 25%"
+func parseGridTracks(value string) ([]GridTrack, error) {
+	tokens := strings.Fields(value)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("grid columns are empty")
+	}
+
+	tracks := make([]GridTrack, len(tokens))
+	for i, t := range tokens {
+		number, unit := t, gridTrackAbs
+		if strings.HasSuffix(t, "fr") {
+			number, unit = strings.TrimSuffix(t, "fr"), gridTrackFraction
+		} else if strings.HasSuffix(t, "%") {
+			number, unit = strings.TrimSuffix(t, "%"), gridTrackPercent
+		}
+
+		v, err := strconv.ParseFloat(number, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("malformed grid column \"%v\", expected number with optional %% or fr unit", t)
+		}
+		tracks[i] = GridTrack{Value: v, Unit: unit}
+	}
+
+	return tracks, nil
+}
+
+// parseSpan parses number of grid cells that node takes
+func parseSpan(value string) (int, error) {
+	span, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || span < 1 {
+		return 0, fmt.Errorf("malformed span \"%v\", expected positive integer", value)
+	}
+	return span, nil
+}
+
+// grid is resolved sizes of columns and rows of grid container
+type grid struct {
+	columns   []float64
+	rows      []float64
+	columnGap float64
+	rowGap    float64
+}
+
+// newGrid resolves widths of columns. Fractions share space that is left after absolute and percent columns.
+func newGrid(tracks []GridTrack, width float64, columnGap float64, rowGap float64) *grid {
+	if len(tracks) == 0 {
+		tracks = []GridTrack{{Value: 1, Unit: gridTrackFraction}}
+	}
+
+	g := &grid{
+		columns:   make([]float64, len(tracks)),
+		columnGap: columnGap,
+		rowGap:    rowGap,
+	}
+
+	free := width - columnGap*float64(len(tracks)-1)
+	var fractions float64
+	for i, t := range tracks {
+		switch t.Unit {
+		case gridTrackAbs:
+			g.columns[i] = t.Value
+		case gridTrackPercent:
+			g.columns[i] = t.Value / 100 * width
+		case gridTrackFraction:
+			fractions += t.Value
+		}
+		free -= g.columns[i]
+	}
+
+	if fractions > 0 {
+		free = math.Max(0, free)
+		for i, t := range tracks {
+			if t.Unit == gridTrackFraction {
+				g.columns[i] = free * t.Value / fractions
+			}
+		}
+	}
+
+	return g
+}
+
+// gridCell is position of child in grid
+type gridCell struct {
+	row    int
+	column int
+}
+
+// placeCells places children into cells one by one from left to right, skipping cells that are taken
+// by spans of previous children. Cells are placed before children are laid out, so properties of children
+// are given in order of layout, and cells are returned in the same order.
+func (g *grid) placeCells(children []CalculatedProperties) []gridCell {
+	var taken [][]bool
+	isTaken := func(row, column int) bool {
+		return row < len(taken) && taken[row][column]
+	}
+
+	cells := make([]gridCell, len(children))
+
+	var row, column int
+	for i := len(children) - 1; i >= 0; i-- {
+		props := &children[i]
+		if props.AbsolutePosition.Has() {
+			cells[i] = gridCell{row: row}
+			continue
+		}
+
+		columnSpan := g.columnSpan(props.ColumnSpan)
+		for {
+			if column+columnSpan > len(g.columns) {
+				row, column = row+1, 0
+				continue
+			}
+			fits := true
+			for c := column; c < column+columnSpan; c++ {
+				fits = fits && !isTaken(row, c)
+			}
+			if fits {
+				break
+			}
+			column++
+		}
+
+		for r := row; r < row+props.RowSpan; r++ {
+			for r >= len(taken) {
+				taken = append(taken, make([]bool, len(g.columns)))
+			}
+			for c := column; c < column+columnSpan; c++ {
+				taken[r][c] = true
+			}
+		}
+
+		cells[i] = gridCell{row: row, column: column}
+		column += columnSpan
+	}
+
+	g.rows = make([]float64, len(taken))
+
+	return cells
+}
+
+// setCells stores cells of children in their row indices: row of cell is stored in RowIndex
+// and column is stored in InRowIndex
+func (g *grid) setCells(nodes Nodes, level int, from int, cells []gridCell) {
+	j := 0
+	for i := from; i < len(nodes) && j < len(cells); i++ {
+		if nodes[i].Level == level {
+			nodes[i].RowIndex, nodes[i].InRowIndex = cells[j].row, cells[j].column
+			j++
+		}
+	}
+}
+
+func (g *grid) columnSpan(span int) int {
+	if span > len(g.columns) {
+		return len(g.columns)
+	}
+	return span
+}
+
+// cellWidth returns width of cell of child including spanned columns
+func (g *grid) cellWidth(cn *Node) float64 {
+	return g.spanSize(g.columns, cn.InRowIndex, g.columnSpan(cn.Props.ColumnSpan), g.columnGap)
+}
+
+// cellHeight returns height of cell of child including spanned rows
+func (g *grid) cellHeight(cn *Node) float64 {
+	return g.spanSize(g.rows, cn.RowIndex, cn.Props.RowSpan, g.rowGap)
+}
+
+func (g *grid) spanSize(tracks []float64, index int, span int, gap float64) float64 {
+	var size float64
+	for i := index; i < index+span && i < len(tracks); i++ {
+		size += tracks[i]
+	}
+	return size + gap*float64(span-1)
+}
+
+// getWidths returns widths of cells for children with width calculated from content in order of layout,
+// or nil if there are no such children. Children are laid out with these widths at once.
+func (g *grid) getWidths(children []CalculatedProperties, cells []gridCell) []float64 {
+	var widths []float64
+	for i, props := range children {
+		width := -1.0
+		isWidthDerived := props.AspectRatio > 0 && props.Size.H != -1
+		if !props.AbsolutePosition.Has() && props.Size.W == -1 && !isWidthDerived {
+			width = g.spanSize(g.columns, cells[i].column, g.columnSpan(props.ColumnSpan), g.columnGap)
+			width -= props.Margin.Left() + props.Margin.Right()
+			if widths == nil {
+				widths = make([]float64, len(children))
+				for j := range widths {
+					widths[j] = -1
+				}
+			}
+		}
+		if widths != nil {
+			widths[i] = width
+		}
+	}
+	return widths
+}
+
+// calculateRows sets heights of rows so that every child fits into its cell.
+// Children spanning several rows enlarge the last of them if needed.
+func (g *grid) calculateRows(nodes Nodes, level int, from int) {
+	for i := range g.rows {
+		g.rows[i] = 0
+	}
+
+	nodes.IterateChildNodes(level, from, func(cn *Node) {
+		if !cn.IsAbsolutePositioned() && cn.Props.RowSpan == 1 {
+			g.rows[cn.RowIndex] = math.Max(g.rows[cn.RowIndex], cn.OuterSize().H)
+		}
+	})
+
+	nodes.IterateChildNodes(level, from, func(cn *Node) {
+		if cn.IsAbsolutePositioned() || cn.Props.RowSpan == 1 {
+			return
+		}
+		if lack := cn.OuterSize().H - g.cellHeight(cn); lack > 0 {
+			g.rows[cn.RowIndex+cn.Props.RowSpan-1] += lack
+		}
+	})
+}
+
+// getStretchSizes returns heights of cells for children stretched with align in order of layout,
+// or nil if nothing is changed
+func (g *grid) getStretchSizes(nodes Nodes, level int, from int, props *CalculatedProperties) []float64 {
+	changed := make(map[*Node]float64)
+	nodes.IterateChildNodes(level, from, func(cn *Node) {
+		height := g.cellHeight(cn) - cn.Props.Margin.Top() - cn.Props.Margin.Bottom()
+		if !cn.IsAbsolutePositioned() && cn.HasAutoHeight && cn.Size.H != height && getAlign(props, cn) == "stretch" {
+			changed[cn] = height
+		}
+	})
+	return sizesInLayoutOrder(nodes, level, from, changed)
+}
+
+// positionCells places children at their cells, vertically they are aligned within cell with align.
+// Baseline align is not supported in grid, so such children are placed at start.
+func (g *grid) positionCells(nodes Nodes, level int, from int, props *CalculatedProperties) {
+	nodes.IterateChildNodes(level, from, func(cn *Node) {
+		if cn.IsAbsolutePositioned() {
+			return
+		}
+		cn.Pos.Left = g.offset(g.columns, cn.InRowIndex, g.columnGap) + cn.Props.Margin.Left()
+		cn.Pos.Top = g.offset(g.rows, cn.RowIndex, g.rowGap) + cn.Props.Margin.Top()
+		cn.Pos.Top += getAlignOffset(getAlign(props, cn), cn, g.cellHeight(cn), cn.OuterBaseline())
+	})
+}
+
+// offset returns position of track with gaps before it
+func (g *grid) offset(tracks []float64, index int, gap float64) float64 {
+	var offset float64
+	for i := 0; i < index && i < len(tracks); i++ {
+		offset += tracks[i] + gap
+	}
+	return offset
+}
+
+func (g *grid) width() float64 {
+	return g.spanSize(g.columns, 0, len(g.columns), g.columnGap)
+}
+
+func (g *grid) height() float64 {
+	if len(g.rows) == 0 {
+		return 0
+	}
+	return g.spanSize(g.rows, 0, len(g.rows), g.rowGap)
+}
@@ -0,0 +1,35 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGridTracks(t *testing.T) {
+	tracks, err := parseGridTracks("1fr 2.5fr 100 25%")
+	assert.NoError(t, err)
+	assert.Equal(t, []GridTrack{
+		{Value: 1, Unit: gridTrackFraction},
+		{Value: 2.5, Unit: gridTrackFraction},
+		{Value: 100, Unit: gridTrackAbs},
+		{Value: 25, Unit: gridTrackPercent},
+	}, tracks)
+
+	for _, v := range []string{"", "fr", "1px", "-1fr"} {
+		_, err = parseGridTracks(v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestNewGrid(t *testing.T) {
+	tracks, _ := parseGridTracks("1fr 100 25% 3fr")
+	g := newGrid(tracks, 400, 10, 0)
+	assert.Equal(t, []float64{42.5, 100, 100, 127.5}, g.columns)
+	assert.Equal(t, 400.0, g.width())
+
+	// Fractions take nothing if there is no free space
+	tracks, _ = parseGridTracks("1fr 500")
+	g = newGrid(tracks, 400, 0, 0)
+	assert.Equal(t, []float64{0, 500}, g.columns)
+}
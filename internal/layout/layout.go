@@ -3,11 +3,11 @@ package layout
 import (
 	"fmt"
 	"github.com/godknowsiamgood/decorender/internal/fonts"
-	"github.com/godknowsiamgood/decorender/internal/parsing"
 	"github.com/godknowsiamgood/decorender/internal/utils"
 	"github.com/godknowsiamgood/decorender/resources"
 	"github.com/samber/lo"
 	"golang.org/x/image/font"
+	"golang.org/x/text/language"
 	"image/color"
 	"math"
 	"sync"
@@ -20,7 +20,10 @@ type layoutPhaseContext struct {
 	level int
 
 	externalImage resources.ExternalImage
-	cache         *Cache
+	// locale is used for locale-specific text transforms
+	locale language.Tag
+	// forcedSizes are sizes of children forced by parent with grow, shrink and stretch
+	forcedSizes *forcedSizes
 }
 
 var nodesPool = sync.Pool{
@@ -29,25 +32,26 @@ var nodesPool = sync.Pool{
 	},
 }
 
-func Do(pn parsing.Node, userData any, externalImage resources.ExternalImage, cache *Cache) (Nodes, error) {
+func Do(t *Template, userData any, externalImage resources.ExternalImage) (Nodes, error) {
 	nodes := nodesPool.Get().(Nodes)
 
-	err := doLayoutNode(pn, &nodes, layoutPhaseContext{
+	err := doLayoutNode(&t.root, &nodes, layoutPhaseContext{
 		size: utils.Size{},
 		props: CalculatedProperties{
 			FontColor:  color.RGBA{A: 255},
 			LineHeight: -1,
 			FontDescription: fonts.FaceDescription{
-				Family: fonts.DefaultFamily,
-				Size:   16,
-				Weight: 400,
-				Style:  font.StyleNormal,
+				Family:   fonts.DefaultFamily,
+				Size:     16,
+				Weight:   400,
+				Style:    font.StyleNormal,
+				Language: t.locale.String(),
 			},
 		},
 		level:         -1,
 		externalImage: externalImage,
-		cache:         cache,
-	}, userData, nil, 0)
+		locale:        t.locale,
+	}, &evalContext{value: userData})
 
 	if err != nil {
 		return nil, err
@@ -58,9 +62,9 @@ func Do(pn parsing.Node, userData any, externalImage resources.ExternalImage, ca
 		return nil, fmt.Errorf("no nodes to render")
 	}
 
-	if pn.GetScale() != 1.0 {
+	if t.scale != 1.0 {
 		nodes.IterateNodes(func(node *Node) {
-			ScaleAllValues(node, pn.GetScale())
+			ScaleAllValues(node, t.scale)
 		})
 	}
 
@@ -72,34 +76,47 @@ func Release(nodes Nodes) {
 	nodesPool.Put(nodes)
 }
 
-func doLayoutNode(pn parsing.Node, nodes *Nodes, context layoutPhaseContext, value any, parentValue any, currentValueIndex int) error {
+func doLayoutNode(cn *compiledNode, nodes *Nodes, context layoutPhaseContext, parentEC *evalContext) error {
 	nodeLevel := context.level + 1
 
-	forEach, err := replaceWithValues(pn.ForEach, value, parentValue, currentValueIndex, context.cache)
-	if err != nil {
-		return err
-	}
-
-	return RunForEach(value, forEach, func(currentValue any, iteratorValue any, currentValueIndex int) error {
-		if iteratorValue == nil {
-			iteratorValue = parentValue
+	return runNodeForEach(cn, parentEC, func(ec *evalContext) error {
+		if cn.ifCond.isSet {
+			isVisible, err := cn.ifCond.get(ec)
+			if err != nil {
+				return err
+			}
+			if !isVisible {
+				return nil
+			}
 		}
 
-		props := calculateProperties(pn, context, currentValue, iteratorValue, currentValueIndex)
+		props := calculateProperties(cn, context, ec)
+
+		forcedSize := utils.Size{W: -1, H: -1}
+		if context.forcedSizes != nil {
+			forcedSize = context.forcedSizes.take()
+		}
+		resolveSize(&props, forcedSize)
 
 		newContext := context
 		newContext.props = props
 		newContext.level = nodeLevel
+		newContext.forcedSizes = nil
 
 		// Setup context size
 
+		// Content of node without size is limited by its max size
 		if props.Size.W != -1 {
 			newContext.size.W = props.Size.W
+		} else if props.MaxSize.W != -1 {
+			newContext.size.W = getMaxContextSize(newContext.size.W, props.MaxSize.W)
 		}
 		newContext.size.W -= props.Padding.Right() + props.Padding.Left()
 
 		if props.Size.H != -1 {
 			newContext.size.H = props.Size.H
+		} else if props.MaxSize.H != -1 {
+			newContext.size.H = getMaxContextSize(newContext.size.H, props.MaxSize.H)
 		}
 		newContext.size.H -= props.Padding.Top() + props.Padding.Bottom()
 
@@ -107,13 +124,12 @@ func doLayoutNode(pn parsing.Node, nodes *Nodes, context layoutPhaseContext, val
 
 		var textWhitespaceWidth float64
 
-		var text string
-		if pn.Text != "" {
-			text, err = replaceWithValues(pn.Text, currentValue, iteratorValue, currentValueIndex, context.cache)
-			if err != nil {
-				return err
-			}
+		text, err := cn.text.get(ec)
+		if err != nil {
+			return err
 		}
+		text = transformText(text, props.TextTransform, context.locale)
+		isText := text != "" || len(cn.spans) > 0
 
 		from := len(*nodes)
 
@@ -121,13 +137,78 @@ func doLayoutNode(pn parsing.Node, nodes *Nodes, context layoutPhaseContext, val
 		// and for traversing reasons later at render phase,
 		// all children in slice are in reverse order.
 
+		layoutInnerNodes := func() error {
+			skippedByElse, err := getSkippedByElse(cn.inner, ec)
+			if err != nil {
+				return err
+			}
+			for i := len(cn.inner) - 1; i >= 0; i-- {
+				if skippedByElse != nil && skippedByElse[i] {
+					continue
+				}
+				if err = doLayoutNode(&cn.inner[i], nodes, newContext, ec); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		// layoutInnerNodesAgain lays out children with sizes forced by this node,
+		// so their content is placed accordingly, but they are kept in the same rows
+		forced := &forcedSizes{}
+		layoutInnerNodesAgain := func() error {
+			if resizeLeafNodes(*nodes, nodeLevel+1, from, forced) {
+				return nil
+			}
+
+			rows := getChildRows(*nodes, nodeLevel+1, from)
+
+			*nodes = (*nodes)[:from]
+			forced.next = 0
+			newContext.forcedSizes = forced
+			if err := layoutInnerNodes(); err != nil {
+				return err
+			}
+			newContext.forcedSizes = nil
+
+			setChildRows(*nodes, nodeLevel+1, from, rows)
+			return nil
+		}
+
+		// Cells of grid are known before children are laid out, so children are laid out with widths of their cells at once
+		var g *grid
+		var gridCells []gridCell
+		if props.IsChildrenDirectionGrid && !isText {
+			children, err := getChildrenProps(cn.inner, newContext, ec)
+			if err != nil {
+				return err
+			}
+			if lo.ContainsBy(children, func(p CalculatedProperties) bool { return !p.AbsolutePosition.Has() }) {
+				g = newGrid(props.GridColumns, newContext.size.W, props.GridColumnGap, props.GridRowGap)
+				gridCells = g.placeCells(children)
+				forced.widths = g.getWidths(children, gridCells)
+			}
+		}
+
+		if text != "" && props.IsFontSizeFit {
+			props.FontDescription = getFitFontDescription(props, getFitFontSize(text, props, newContext.size))
+			newContext.props = props
+		}
+
 		if text != "" {
 			textWhitespaceWidth = spitTextToNodes(nodes, text, newContext)
+		} else if len(cn.spans) > 0 {
+			if textWhitespaceWidth, err = spitSpansToNodes(nodes, cn.spans, newContext, ec); err != nil {
+				return err
+			}
 		} else {
-			for i := len(pn.Inner) - 1; i >= 0; i-- {
-				if err = doLayoutNode(pn.Inner[i], nodes, newContext, currentValue, iteratorValue, currentValueIndex); err != nil {
-					return err
-				}
+			if forced.widths != nil {
+				newContext.forcedSizes = forced
+			}
+			err = layoutInnerNodes()
+			newContext.forcedSizes = nil
+			if err != nil {
+				return err
 			}
 		}
 
@@ -140,8 +221,24 @@ func doLayoutNode(pn parsing.Node, nodes *Nodes, context layoutPhaseContext, val
 			}
 		})
 
-		// Apply wrapping and aligning. All of this can be applied only for not absolute positioned elements
-		if childCount > 0 {
+		// Height of rows of row and grid children, rows can be higher than their children because of align
+		var rowsHeight float64
+		var rowsCount int
+
+		if g != nil {
+			g.setCells(*nodes, childrenNodesLevel, from, gridCells)
+			g.calculateRows(*nodes, childrenNodesLevel, from)
+
+			if forced.heights = g.getStretchSizes(*nodes, childrenNodesLevel, from, &props); forced.heights != nil {
+				if err = layoutInnerNodesAgain(); err != nil {
+					return err
+				}
+			}
+
+			g.positionCells(*nodes, childrenNodesLevel, from, &props)
+			rowsHeight = g.height()
+		} else if childCount > 0 {
+			// Apply wrapping and aligning. All of this can be applied only for not absolute positioned elements
 			isDirectionRow := props.IsChildrenDirectionRow
 
 			// do child wrapping
@@ -151,16 +248,18 @@ func doLayoutNode(pn parsing.Node, nodes *Nodes, context layoutPhaseContext, val
 				var currentWidth float64
 
 				var prevNodeInRow *Node
+				isRowEmpty := true
 				nodes.IterateChildNodes(childrenNodesLevel, from, func(node *Node) {
 					if !node.IsAbsolutePositioned() {
-						if props.IsWrappingEnabled && currentWidth+node.Size.W > newContext.size.W {
+						// Node wider than parent is placed to the current row if it is empty, so rows are never empty
+						if props.IsWrappingEnabled && !isRowEmpty && currentWidth+node.OuterSize().W > newContext.size.W {
 							currentWidth = 0
 							currentRowIndex += 1
 							currentInRowIndex = 0
 
-							// Maybe we can wrap whole-hyphened word to look it better
-							if prevNodeInRow != nil && prevNodeInRow.TextHasHyphenAtEnd {
-								wholeWidth := prevNodeInRow.Size.W + node.Size.W
+							// Maybe we can wrap whole-hyphened word to look it better, unless it is the only one in its row
+							if prevNodeInRow != nil && prevNodeInRow.TextHasHyphenAtEnd && prevNodeInRow.InRowIndex > 0 {
+								wholeWidth := prevNodeInRow.OuterSize().W + node.OuterSize().W
 								if wholeWidth <= newContext.size.W {
 									prevNodeInRow.InRowIndex = 0
 									prevNodeInRow.RowIndex = currentRowIndex
@@ -170,7 +269,8 @@ func doLayoutNode(pn parsing.Node, nodes *Nodes, context layoutPhaseContext, val
 
 							prevNodeInRow = nil
 						}
-						currentWidth += node.Size.W + lo.Ternary(node.TextHasHyphenAtEnd, 0, textWhitespaceWidth) + props.InnerGap
+						currentWidth += node.OuterSize().W + lo.Ternary(node.TextHasHyphenAtEnd, 0, textWhitespaceWidth) + props.InnerGap
+						isRowEmpty = false
 					}
 
 					node.RowIndex = currentRowIndex
@@ -179,8 +279,11 @@ func doLayoutNode(pn parsing.Node, nodes *Nodes, context layoutPhaseContext, val
 					prevNodeInRow = node
 				})
 
-				if text != "" {
-					mergeTextNodes(nodes, childrenNodesLevel, from)
+				if isText {
+					mergeTextNodes(nodes, childrenNodesLevel, from, getTextSeparateRows(*nodes, childrenNodesLevel, from, &props))
+					if props.MaxLines > 0 {
+						truncateTextRows(nodes, childrenNodesLevel, from, &props, textWhitespaceWidth, newContext.size.W)
+					}
 				}
 			} else {
 				i := 0
@@ -190,27 +293,65 @@ func doLayoutNode(pn parsing.Node, nodes *Nodes, context layoutPhaseContext, val
 				})
 			}
 
+			// do grow and shrink, and then stretch children in rows with new sizes
+			if !isText {
+				if sizes := getFlexSizes(*nodes, childrenNodesLevel, from, &props, newContext.size, textWhitespaceWidth); sizes != nil {
+					if isDirectionRow {
+						forced.widths = sizes
+					} else {
+						forced.heights = sizes
+					}
+					if err = layoutInnerNodesAgain(); err != nil {
+						return err
+					}
+				}
+				if isDirectionRow {
+					if heights := getStretchSizes(*nodes, childrenNodesLevel, from, &props, newContext.size.H); heights != nil {
+						forced.heights = heights
+						if err = layoutInnerNodesAgain(); err != nil {
+							return err
+						}
+					}
+				}
+			}
+
 			// do justify and vertical position for rows
 
 			if props.IsChildrenDirectionRow {
 				var top float64
+				minRowHeight := getMinRowHeight(*nodes, childrenNodesLevel, from, &props, newContext.size.H)
+				justify := props.Justify
+				if isText {
+					justify = getTextJustify(&props)
+				}
 				nodes.IterateRows(childrenNodesLevel, from, func(rowIndex int, _ *Node) {
 					totalRowSize, countInRow := nodes.RowTotalWidth(childrenNodesLevel, from, rowIndex, textWhitespaceWidth, props.InnerGap)
-					offset, gap := getJustifyOffsetAndGap(props.Justify, props.InnerGap, totalRowSize, newContext.size.W, countInRow)
+					offset, gap := getJustifyOffsetAndGap(justify, props.InnerGap, totalRowSize, newContext.size.W, countInRow)
+					whitespaceWidth := textWhitespaceWidth
+					if isText {
+						whitespaceWidth += getTextWordGap(*nodes, childrenNodesLevel, from, rowIndex, &props, totalRowSize, newContext.size.W)
+					}
+					rowHeight, rowBaseline := getRowHeightAndBaseline(*nodes, childrenNodesLevel, from, rowIndex, &props, minRowHeight)
 
-					var maxHeight float64
 					nodes.IterateRow(childrenNodesLevel, from, rowIndex, func(cn *Node) {
 						if cn.IsAbsolutePositioned() {
 							return
 						}
-						cn.Pos.Left = offset
-						cn.Pos.Top = top
-						offset += cn.Size.W + lo.Ternary(cn.TextHasHyphenAtEnd, 0, textWhitespaceWidth) + gap
-						maxHeight = math.Max(maxHeight, cn.Size.H)
+						cn.Pos.Left = offset + cn.Props.Margin.Left()
+						cn.Pos.Top = top + getAlignOffset(getAlign(&props, cn), cn, rowHeight, rowBaseline) + cn.Props.Margin.Top()
+						offset += cn.OuterSize().W + lo.Ternary(cn.TextHasHyphenAtEnd, 0, whitespaceWidth) + gap
 					})
 
-					top += maxHeight + gap
+					if countInRow > 0 {
+						rowsHeight += rowHeight + lo.Ternary(rowsCount > 0, props.InnerGap, 0)
+						rowsCount++
+					}
+					top += rowHeight + gap
 				})
+
+				if isText {
+					setTextDecorationWidths(*nodes, childrenNodesLevel, from)
+				}
 			} else {
 				totalHeight, count := nodes.RowsTotalHeight(childrenNodesLevel, from, props.InnerGap)
 				offset, gap := getJustifyOffsetAndGap(props.Justify, props.InnerGap, totalHeight, newContext.size.H, count)
@@ -218,39 +359,50 @@ func doLayoutNode(pn parsing.Node, nodes *Nodes, context layoutPhaseContext, val
 					if node.IsAbsolutePositioned() {
 						return
 					}
-					node.Pos.Top = offset
-					offset += node.Size.H + gap
+					node.Pos.Top = offset + node.Props.Margin.Top()
+					offset += node.OuterSize().H + gap
 				})
 			}
 
 			// do horizontal align for column children
 
 			if !isDirectionRow {
-				nodes.IterateRow(childrenNodesLevel, from, 0, func(cn *Node) {
+				nodes.IterateChildNodes(childrenNodesLevel, from, func(cn *Node) {
 					if cn.IsAbsolutePositioned() {
 						return
 					}
 
+					cn.Pos.Left = cn.Props.Margin.Left()
 					if props.ChildrenColumnAlign == "center" {
-						cn.Pos.Left = newContext.size.W/2 - cn.Size.W/2
+						cn.Pos.Left += newContext.size.W/2 - cn.OuterSize().W/2
 					} else if props.ChildrenColumnAlign == "right" {
-						cn.Pos.Left = newContext.size.W - cn.Size.W
+						cn.Pos.Left += newContext.size.W - cn.OuterSize().W
 					}
 				})
 			}
 		}
 
-		if props.Size.W == -1 {
+		hasAutoWidth := props.Size.W == -1
+		if hasAutoWidth && g != nil {
+			props.Size.W = g.width() + props.Padding.Left() + props.Padding.Right()
+		} else if hasAutoWidth {
 			nodes.IterateRows(childrenNodesLevel, from, func(rowIndex int, _ *Node) {
 				rowWidth, _ := nodes.RowTotalWidth(childrenNodesLevel, from, rowIndex, textWhitespaceWidth, props.InnerGap)
 				props.Size.W = math.Max(props.Size.W, rowWidth)
 			})
 			props.Size.W = math.Max(0, props.Size.W+props.Padding.Left()+props.Padding.Right())
 		}
+		if hasAutoWidth {
+			props.Size.W = clampSize(props.Size.W, props.MinSize.W, props.MaxSize.W)
+		}
 
-		if props.Size.H == -1 {
-			height, _ := nodes.RowsTotalHeight(childrenNodesLevel, from, props.InnerGap)
-			props.Size.H = math.Max(0, height+props.Padding.Top()+props.Padding.Bottom())
+		hasAutoHeight := props.Size.H == -1
+		if hasAutoHeight {
+			height := rowsHeight
+			if !props.IsChildrenDirectionRow && g == nil {
+				height, _ = nodes.RowsTotalHeight(childrenNodesLevel, from, props.InnerGap)
+			}
+			props.Size.H = clampSize(math.Max(0, height+props.Padding.Top()+props.Padding.Bottom()), props.MinSize.H, props.MaxSize.H)
 		}
 
 		applyAbsolutePositions(nodes, childrenNodesLevel, from, &props)
@@ -261,17 +413,20 @@ func doLayoutNode(pn parsing.Node, nodes *Nodes, context layoutPhaseContext, val
 			cn.Pos.Top += cn.Props.Offset.Top()
 		})
 
-		imageVal, err := replaceWithValues(pn.Image, currentValue, iteratorValue, currentValueIndex, context.cache)
+		imageVal, err := cn.image.get(ec)
 		if err != nil {
 			return err
 		}
 
 		ln := Node{
-			Id:    pn.Id,
-			Size:  props.Size,
-			Props: props,
-			Image: imageVal,
-			Level: nodeLevel,
+			Id:            cn.id,
+			Size:          props.Size,
+			Props:         props,
+			Image:         imageVal,
+			Level:         nodeLevel,
+			Baseline:      getBaseline(*nodes, childrenNodesLevel, from, &props),
+			HasAutoWidth:  hasAutoWidth,
+			HasAutoHeight: hasAutoHeight,
 			// Pos is not set here, because parent is responsible for doing this
 		}
 
@@ -285,6 +440,160 @@ func doLayoutNode(pn parsing.Node, nodes *Nodes, context layoutPhaseContext, val
 	})
 }
 
+// getChildrenProps returns properties of children in order of layout without laying them out,
+// e.g. to place them into grid cells beforehand
+func getChildrenProps(cns []compiledNode, context layoutPhaseContext, parentEC *evalContext) ([]CalculatedProperties, error) {
+	skippedByElse, err := getSkippedByElse(cns, parentEC)
+	if err != nil {
+		return nil, err
+	}
+
+	var children []CalculatedProperties
+	for i := len(cns) - 1; i >= 0; i-- {
+		if skippedByElse != nil && skippedByElse[i] {
+			continue
+		}
+		cn := &cns[i]
+		err = runNodeForEach(cn, parentEC, func(ec *evalContext) error {
+			if cn.ifCond.isSet {
+				isVisible, err := cn.ifCond.get(ec)
+				if err != nil || !isVisible {
+					return err
+				}
+			}
+			children = append(children, calculateProperties(cn, context, ec))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return children, nil
+}
+
+// runNodeForEach calls cb for every iteration of node forEach, or once with parent context if there is no forEach
+func runNodeForEach(cn *compiledNode, parentEC *evalContext, cb func(ec *evalContext) error) error {
+	if !cn.forEach.isSet && len(cn.params) == 0 {
+		return cb(parentEC)
+	}
+
+	iterations, err := parentEC.getIterations(cn)
+	if err != nil {
+		return err
+	}
+
+	for _, ec := range iterations {
+		if err = cb(ec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSkippedByElse returns which nodes with else should not be rendered
+// because some previous node in if-else chain is rendered.
+// Result is nil if there are no nodes with else.
+func getSkippedByElse(cns []compiledNode, ec *evalContext) ([]bool, error) {
+	if !lo.ContainsBy(cns, func(cn compiledNode) bool { return cn.elseCond.isSet }) {
+		return nil, nil
+	}
+
+	skipped := make([]bool, len(cns))
+
+	var isChainRendered bool
+	for i := range cns {
+		cn := &cns[i]
+
+		isElse, err := cn.elseCond.get(ec)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isElse {
+			isChainRendered = false
+		} else if isChainRendered {
+			skipped[i] = true
+			continue
+		}
+
+		// Whether node is rendered matters only for following nodes with else
+		if i+1 < len(cns) && cns[i+1].elseCond.isSet {
+			isRendered, err := hasNodesToRender(cn, ec)
+			if err != nil {
+				return nil, err
+			}
+			isChainRendered = isChainRendered || isRendered
+		}
+	}
+
+	return skipped, nil
+}
+
+// hasNodesToRender checks if node will produce at least one node considering its forEach and if
+func hasNodesToRender(cn *compiledNode, parentEC *evalContext) (bool, error) {
+	var hasNodes bool
+	err := runNodeForEach(cn, parentEC, func(ec *evalContext) error {
+		isVisible := true
+		if cn.ifCond.isSet {
+			var err error
+			if isVisible, err = cn.ifCond.get(ec); err != nil {
+				return err
+			}
+		}
+		hasNodes = hasNodes || isVisible
+		return nil
+	})
+
+	return hasNodes, err
+}
+
+// resolveSize applies size forced by parent and limits size with min and max sizes.
+// Missing dimension is derived from aspect ratio after that, so it follows clamped or forced dimension,
+// e.g. height follows width of grid cell.
+func resolveSize(props *CalculatedProperties, forced utils.Size) {
+	if forced.W != -1 {
+		props.Size.W = forced.W
+	}
+	if forced.H != -1 {
+		props.Size.H = forced.H
+	}
+
+	isHeightDerived := props.AspectRatio > 0 && props.Size.W != -1 && props.Size.H == -1
+	isWidthDerived := props.AspectRatio > 0 && props.Size.H != -1 && props.Size.W == -1
+
+	if props.Size.W != -1 {
+		props.Size.W = clampSize(props.Size.W, props.MinSize.W, props.MaxSize.W)
+	}
+	if props.Size.H != -1 {
+		props.Size.H = clampSize(props.Size.H, props.MinSize.H, props.MaxSize.H)
+	}
+
+	if isHeightDerived {
+		props.Size.H = clampSize(props.Size.W/props.AspectRatio, props.MinSize.H, props.MaxSize.H)
+	}
+	if isWidthDerived {
+		props.Size.W = clampSize(props.Size.H*props.AspectRatio, props.MinSize.W, props.MaxSize.W)
+	}
+}
+
+// clampSize limits size with min and max sizes, max is -1 if there is no limit
+func clampSize(size float64, min float64, max float64) float64 {
+	if max != -1 && size > max {
+		size = max
+	}
+	return math.Max(size, min)
+}
+
+// getMaxContextSize returns size available for content of node, that is limited by its max size.
+// If available size is unknown, e.g. for root node, max size is used.
+func getMaxContextSize(size float64, max float64) float64 {
+	if size <= 0 {
+		return max
+	}
+	return math.Min(size, max)
+}
+
 func getJustifyOffsetAndGap(justifyProp string, gapProp float64, totalSize float64, parentSize float64, count int) (offset float64, gap float64) {
 	switch justifyProp {
 	case "center":
@@ -300,3 +609,62 @@ func getJustifyOffsetAndGap(justifyProp string, gapProp float64, totalSize float
 	gap = math.Max(gap, gapProp)
 	return offset, gap
 }
+
+// resizeLeafNodes applies forced sizes to children in place, if every resized child has no children,
+// so its content doesn't depend on its size. Otherwise, nothing is changed and false is returned.
+func resizeLeafNodes(nodes Nodes, level int, from int, forced *forcedSizes) bool {
+	var children []int
+	for i := from; i < len(nodes); i++ {
+		if nodes[i].Level != level {
+			continue
+		}
+		j := len(children)
+		if (j < len(forced.widths) && forced.widths[j] != -1) || (j < len(forced.heights) && forced.heights[j] != -1) {
+			hasChildren := i > from && nodes[i-1].Level > level
+			if hasChildren || nodes[i].Props.AspectRatio > 0 {
+				return false
+			}
+		}
+		children = append(children, i)
+	}
+
+	forced.next = 0
+	for _, i := range children {
+		n := &nodes[i]
+		size := forced.take()
+		if size.W != -1 {
+			n.Size.W = clampSize(size.W, n.Props.MinSize.W, n.Props.MaxSize.W)
+			n.HasAutoWidth = false
+		}
+		if size.H != -1 {
+			n.Size.H = clampSize(size.H, n.Props.MinSize.H, n.Props.MaxSize.H)
+			n.HasAutoHeight = false
+		}
+		n.Props.Size = n.Size
+		n.Baseline = n.Size.H
+	}
+
+	return true
+}
+
+// getChildRows returns row indices of children in order of layout
+func getChildRows(nodes Nodes, level int, from int) [][2]int {
+	var rows [][2]int
+	for i := from; i < len(nodes); i++ {
+		if nodes[i].Level == level {
+			rows = append(rows, [2]int{nodes[i].RowIndex, nodes[i].InRowIndex})
+		}
+	}
+	return rows
+}
+
+// setChildRows restores row indices of children laid out again
+func setChildRows(nodes Nodes, level int, from int, rows [][2]int) {
+	j := 0
+	for i := from; i < len(nodes) && j < len(rows); i++ {
+		if nodes[i].Level == level {
+			nodes[i].RowIndex, nodes[i].InRowIndex = rows[j][0], rows[j][1]
+			j++
+		}
+	}
+}
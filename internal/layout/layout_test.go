@@ -0,0 +1,249 @@
+package layout
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/godknowsiamgood/decorender/internal/fonts"
+	"github.com/godknowsiamgood/decorender/internal/parsing"
+	"github.com/godknowsiamgood/decorender/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveSize(t *testing.T) {
+	noForced := utils.Size{W: -1, H: -1}
+
+	// Height is derived from clamped width
+	props := CalculatedProperties{
+		Size:        utils.Size{W: 400, H: -1},
+		MaxSize:     utils.Size{W: 160, H: -1},
+		AspectRatio: 16.0 / 9,
+	}
+	resolveSize(&props, noForced)
+	assert.InDelta(t, 160, props.Size.W, 0.001)
+	assert.InDelta(t, 90, props.Size.H, 0.001)
+
+	// Width is derived from forced height, e.g. stretched one
+	props = CalculatedProperties{
+		Size:        utils.Size{W: -1, H: -1},
+		MaxSize:     utils.Size{W: -1, H: -1},
+		AspectRatio: 2,
+	}
+	resolveSize(&props, utils.Size{W: -1, H: 50})
+	assert.Equal(t, utils.Size{W: 100, H: 50}, props.Size)
+
+	// Derived dimension is clamped too
+	props = CalculatedProperties{
+		Size:        utils.Size{W: 300, H: -1},
+		MinSize:     utils.Size{H: 200},
+		MaxSize:     utils.Size{W: -1, H: -1},
+		AspectRatio: 3,
+	}
+	resolveSize(&props, noForced)
+	assert.Equal(t, utils.Size{W: 300, H: 200}, props.Size)
+
+	// Aspect ratio is ignored when both dimensions are set
+	props = CalculatedProperties{
+		Size:        utils.Size{W: 100, H: 20},
+		MaxSize:     utils.Size{W: -1, H: -1},
+		AspectRatio: 2,
+	}
+	resolveSize(&props, utils.Size{W: 60, H: -1})
+	assert.Equal(t, utils.Size{W: 60, H: 20}, props.Size)
+}
+
+func TestComponentParamsScope(t *testing.T) {
+	assert.NoError(t, fonts.LoadFaces(nil, nil))
+
+	// label is evaluated in forEach iteration of node with use, not in iterations inside component
+	root, err := parsing.ExpandComponents(parsing.Node{
+		Components: map[string]parsing.Component{
+			"card": {
+				Params: map[string]string{"label": ""},
+				Node:   parsing.Node{Inner: []parsing.Node{{ForEach: "2", Text: "~ label"}}},
+			},
+		},
+		Inner: []parsing.Node{{Use: "card", ForEach: "Names", Props: map[string]string{"label": "~ value"}}},
+	})
+	assert.NoError(t, err)
+
+	type data struct {
+		Names []string
+	}
+
+	template, errs := Compile(root, CompileOptions{DataType: reflect.TypeOf(data{})})
+	assert.Empty(t, errs)
+
+	nodes, err := Do(template, data{Names: []string{"Ann", "Bob"}}, nil)
+	assert.NoError(t, err)
+	defer Release(nodes)
+
+	var texts []string
+	for _, n := range nodes {
+		if n.Text != "" {
+			texts = append(texts, n.Text)
+		}
+	}
+	assert.ElementsMatch(t, []string{"Ann", "Ann", "Bob", "Bob"}, texts)
+}
+
+func TestComponentLiteralParams(t *testing.T) {
+	// Numbers and booleans keep their types, strings stay strings even if they look like numbers
+	nodes := layoutTemplate(t, `
+components:
+  price:
+    params:
+      n: 1
+      rate: 0.5
+      show: false
+      code: ""
+    text: '~ show && code == "7" ? string(n * 10 * rate) + code : "hidden"'
+inner:
+  - use: price
+    props:
+      n: 3
+      rate: 2.0
+      show: True
+      code: "7"
+  - use: price`)
+
+	var texts []string
+	for i := len(nodes) - 1; i >= 0; i-- {
+		if nodes[i].Text != "" {
+			texts = append(texts, nodes[i].Text)
+		}
+	}
+	assert.Equal(t, []string{"607", "hidden"}, texts)
+}
+
+// layoutTemplate does layout of template without data, nodes are released when test ends
+func layoutTemplate(t *testing.T, template string) Nodes {
+	t.Helper()
+
+	if err := fonts.LoadFaces(nil, nil); err != nil {
+		t.Fatalf("unexpected error while loading fonts: %v", err)
+	}
+
+	root, _, err := parsing.Load("", []byte(template), nil)
+	if err != nil {
+		t.Fatalf("unexpected error while yaml parse: %v", err)
+	}
+	if root, err = parsing.ExpandComponents(root); err != nil {
+		t.Fatalf("unexpected error while components expand: %v", err)
+	}
+
+	compiled, errs := Compile(root, CompileOptions{})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors while compile: %v", errs)
+	}
+
+	nodes, err := Do(compiled, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error while layout: %v", err)
+	}
+	t.Cleanup(func() { Release(nodes) })
+
+	return nodes
+}
+
+func TestMinAndMaxSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		size     utils.Size
+	}{
+		{
+			name: "content wraps at max width",
+			template: `
+maxWidth: 3
+innerDirection: row
+inner:
+  - forEach: 5
+    size: 1 1`,
+			size: utils.Size{W: 3, H: 2},
+		},
+		{
+			name: "content is enlarged to min width",
+			template: `
+minWidth: 4
+innerDirection: row
+inner:
+  - size: 1 1`,
+			size: utils.Size{W: 4, H: 1},
+		},
+		{
+			name: "size is clamped",
+			template: `
+size: 10 10
+maxWidth: 5
+minHeight: 12`,
+			size: utils.Size{W: 5, H: 12},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := layoutTemplate(t, tt.template)
+			assert.Equal(t, tt.size, nodes[len(nodes)-1].Size)
+		})
+	}
+
+	// Width of child is limited by percent of parent
+	nodes := layoutTemplate(t, `
+width: 6
+inner:
+  - width: 100%
+    maxWidth: 50%
+    height: 1`)
+	assert.Equal(t, utils.Size{W: 3, H: 1}, nodes[len(nodes)-2].Size)
+}
+
+func TestAspectRatio(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		size     utils.Size
+	}{
+		{
+			name: "height from percent width",
+			template: `
+width: 16
+inner:
+  - width: 100%
+    aspectRatio: 16/9`,
+			size: utils.Size{W: 16, H: 9},
+		},
+		{
+			name: "width from height",
+			template: `
+height: 10
+aspectRatio: 0.5`,
+			size: utils.Size{W: 5, H: 10},
+		},
+		{
+			name: "height from width of grid cell",
+			template: `
+width: 8
+innerDirection: grid
+gridColumns: 1fr 1fr
+inner:
+  - aspectRatio: 2`,
+			size: utils.Size{W: 8, H: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := layoutTemplate(t, tt.template)
+			assert.Equal(t, tt.size, nodes[len(nodes)-1].Size)
+		})
+	}
+
+	// Height of stretched absolute node is derived from its width
+	nodes := layoutTemplate(t, `
+size: 16 20
+inner:
+  - absolute: left right top
+    aspectRatio: 2`)
+	assert.Equal(t, utils.Size{W: 16, H: 8}, nodes[len(nodes)-2].Size)
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/godknowsiamgood/decorender/internal/fonts"
-	"github.com/godknowsiamgood/decorender/internal/parsing"
 	"github.com/godknowsiamgood/decorender/internal/utils"
 	"github.com/samber/lo"
 	"golang.org/x/image/font"
@@ -22,111 +21,237 @@ const (
 	unitHeight
 )
 
-// calculateProperties is currently ugly function that needs refactoring.
-// Maybe we should introduce some fields generic configuration.
-func calculateProperties(n parsing.Node, context layoutPhaseContext, data any, parentData any, currentValueIndex int) CalculatedProperties {
-	padding, _ := parseNValues(n.Padding, 4, context.size.W, context.size.H, data, parentData, currentValueIndex, false, false, context.cache)
-	lineHeight, _ := parseNValues(n.LineHeight, 1, context.size.W, context.size.H, data, parentData, currentValueIndex, false, false, context.cache)
-	borderRadius, _ := parseNValues(n.BorderRadius, 4, context.size.W, context.size.H, data, parentData, currentValueIndex, false, false, context.cache)
+// Allowed values of enum properties, first value is default
+var (
+	innerDirectionValues   = []string{"column", "row", "grid"}
+	justifyValues          = []string{"start", "center", "end", "space-between", "space-evenly"}
+	innerColumnAlignValues = []string{"left", "center", "right"}
+	alignValues            = []string{"start", "center", "end", "stretch", "baseline"}
+	alignSelfValues        = []string{"auto", "start", "center", "end", "stretch", "baseline"}
+	innerWrapValues        = []string{"wrap", "none"}
+	textAlignValues        = []string{"left", "center", "right", "justify"}
+	textTransformValues    = []string{"none", "uppercase", "lowercase", "capitalize"}
+	bkgImageSizeValues     = []string{"cover", "contain"}
+	overflowValues         = []string{"visible", "hidden"}
+	fontStyleValues        = []string{"normal", "italic"}
+)
+
+// calculateProperties evaluates all properties of compiled node for current iteration.
+// Malformed templated values are ignored, and default or inherited values are used instead.
+func calculateProperties(cn *compiledNode, context layoutPhaseContext, ec *evalContext) CalculatedProperties {
+	parentW, parentH := context.size.W, context.size.H
+
+	padding := cn.padding.getOr(ec, unitValues{}).resolve(parentW, parentH, false)
+	margin := cn.margin.getOr(ec, unitValues{}).resolve(parentW, parentH, false)
+	borderRadius := cn.borderRadius.getOr(ec, unitValues{}).resolve(parentW, parentH, false)
+
+	sz := utils.FourValues{-1, -1}
+	if v, ok := cn.size.lookup(ec); ok {
+		sz = v.resolve(parentW, parentH, false)
+	}
+	if v, ok := cn.width.lookup(ec); ok {
+		sz[0] = v.resolve(parentW, parentH, false)[0]
+	}
+	if v, ok := cn.height.lookup(ec); ok {
+		sz[1] = v.resolve(parentW, parentH, true)[0]
+	}
 
-	sz, szErr := parseNValues(n.Size, 2, context.size.W, context.size.H, data, parentData, currentValueIndex, false, false, context.cache)
-	width, widthErr := parseNValues(n.Width, 1, context.size.W, context.size.H, data, parentData, currentValueIndex, true, false, context.cache)
-	height, heightErr := parseNValues(n.Height, 1, context.size.W, context.size.H, data, parentData, currentValueIndex, false, false, context.cache)
-	if szErr != nil {
-		sz[0], sz[1] = -1, -1
+	minSize := utils.Size{}
+	maxSize := utils.Size{W: -1, H: -1}
+	if v, ok := cn.minWidth.lookup(ec); ok {
+		minSize.W = v.resolve(parentW, parentH, false)[0]
+	}
+	if v, ok := cn.maxWidth.lookup(ec); ok {
+		maxSize.W = v.resolve(parentW, parentH, false)[0]
 	}
-	if widthErr == nil {
-		sz[0] = width[0]
+	if v, ok := cn.minHeight.lookup(ec); ok {
+		minSize.H = v.resolve(parentW, parentH, true)[0]
 	}
-	if heightErr == nil {
-		sz[1] = height[0]
+	if v, ok := cn.maxHeight.lookup(ec); ok {
+		maxSize.H = v.resolve(parentW, parentH, true)[0]
 	}
 
-	anchors := parseAnchors(n.Absolute, data, parentData, currentValueIndex, context.cache)
+	anchors := cn.absolute.getOr(ec, utils.AbsolutePosition{})
 	if anchors.HasTop() && anchors.HasBottom() {
-		sz[1] = context.size.H - anchors.Top() - anchors.Bottom()
+		sz[1] = parentH - anchors.Top() - anchors.Bottom()
 	}
 	if anchors.HasLeft() && anchors.HasRight() {
-		sz[0] = context.size.W - anchors.Left() - anchors.Right()
+		sz[0] = parentW - anchors.Left() - anchors.Right()
 	}
 
-	backgroundColor := color.RGBA{A: 0}
-	if n.BkgColor != "" {
-		backgroundColor, _ = parseColor(replaceWithValuesUnsafe(n.BkgColor, data, parentData, currentValueIndex, context.cache))
-	}
+	// Missing dimension is derived from the known one later, when size is forced and clamped, see resolveSize
+	aspectRatio := cn.aspectRatio.getOr(ec, 0)
 
-	bkgImageSize := validateStringValue(n.BkgImageSize, []string{"cover", "contain"})
+	backgroundColor := cn.bkgColor.getOr(ec, color.RGBA{A: 0})
+	bkgImageSize := cn.bkgImageSize.getOr(ec, bkgImageSizeValues[0])
+	overflow := cn.overflow.getOr(ec, overflowValues[0])
 
 	fontColor := context.props.FontColor // inherited
-	if n.FontColor != "" {
-		fontColor, _ = parseColor(replaceWithValuesUnsafe(n.FontColor, data, parentData, currentValueIndex, context.cache))
-	}
-	if n.Color != "" {
-		fontColor, _ = parseColor(replaceWithValuesUnsafe(n.Color, data, parentData, currentValueIndex, context.cache))
-	}
+	fontColor = cn.fontColor.getOr(ec, fontColor)
+	fontColor = cn.color.getOr(ec, fontColor)
 
 	fontDescription := context.props.FontDescription // inherited
-	fontDescription = parseFontString(n.Font, fontDescription, context.size.W, context.size.H, data, parentData, currentValueIndex, context.cache)
-	if n.FontFamily != "" {
-		fontDescription.Family = replaceWithValuesUnsafe(n.FontFamily, data, parentData, currentValueIndex, context.cache)
+	if v, ok := cn.font.lookup(ec); ok {
+		fontDescription = v.apply(fontDescription, parentW, parentH)
 	}
-	if n.FontSize != "" {
-		v, err := parseNValues(n.FontSize, 1, context.size.W, context.size.H, data, parentData, currentValueIndex, true, false, context.cache)
-		if err == nil {
-			fontDescription.Size = v[0]
+	fontDescription.Family = cn.fontFamily.getOr(ec, fontDescription.Family)
+	isFontSizeFit := false
+	if v, ok := cn.fontSize.lookup(ec); ok {
+		if v.isFit {
+			isFontSizeFit = true
+		} else {
+			fontDescription.Size = v.size.resolve(parentW, parentH, false)[0]
 		}
 	}
-	if n.FontWeight != "" {
-		v, err := parseNValues(n.FontWeight, 1, context.size.W, context.size.H, data, parentData, currentValueIndex, true, false, context.cache)
-		if err == nil {
-			fontDescription.Weight = int(v[0])
+	minFontSize := cn.minFontSize.getOr(ec, unitValues{}).resolve(parentW, parentH, false)[0]
+	maxFontSize := -1.0
+	if v, ok := cn.maxFontSize.lookup(ec); ok {
+		maxFontSize = v.resolve(parentW, parentH, false)[0]
+	}
+	letterSpacingEm := 0.0
+	if v, ok := cn.letterSpacing.lookup(ec); ok {
+		fontDescription.LetterSpacing = v.resolve(fontDescription.Size)
+		if v.isEm {
+			letterSpacingEm = v.value
 		}
 	}
-	if n.FontStyle != "" {
-		fontDescription.Style = lo.Ternary(replaceWithValuesUnsafe(n.FontStyle, data, parentData, currentValueIndex, context.cache) == "italic", font.StyleItalic, font.StyleNormal)
+	if v, ok := cn.fontWeight.lookup(ec); ok {
+		fontDescription.Weight = int(v.resolve(parentW, parentH, false)[0])
+	}
+	if v, ok := cn.fontStyle.lookup(ec); ok {
+		fontDescription.Style = lo.Ternary(v == "italic", font.StyleItalic, font.StyleNormal)
 	}
+	fontDescription.Features = cn.fontFeatures.getOr(ec, fontDescription.Features)
+
+	childrenDirection := cn.innerDirection.getOr(ec, innerDirectionValues[0])
+	childrenJustify := cn.justify.getOr(ec, justifyValues[0])
+	childrenColumnAlign := cn.innerColumnAlign.getOr(ec, innerColumnAlignValues[0])
+	childrenAlign := cn.align.getOr(ec, alignValues[0])
+	alignSelf := cn.alignSelf.getOr(ec, alignSelfValues[0])
+	innerGap := cn.innerGap.getOr(ec, unitValues{}).resolve(parentW, parentH, false)
+	childrenWrap := cn.innerWrap.getOr(ec, innerWrapValues[0])
+	textAlign := cn.textAlign.getOr(ec, context.props.TextAlign) // inherited
+	maxLines := cn.maxLines.getOr(ec, 0)
+	ellipsis := cn.ellipsis.getOr(ec, "…")
+
+	textTransform := cn.textTransform.getOr(ec, context.props.TextTransform)    // inherited
+	textDecoration := cn.textDecoration.getOr(ec, context.props.TextDecoration) // inherited
+	textStroke := cn.textStroke.getOr(ec, context.props.TextStroke)             // inherited
+	textShadow := cn.textShadow.getOr(ec, context.props.TextShadow)             // inherited
+
+	gridColumns := cn.gridColumns.getOr(ec, nil)
+	gridGap := innerGap
+	if v, ok := cn.gridGap.lookup(ec); ok {
+		gridGap = v.resolve(parentW, parentH, true)
+	}
+	columnSpan := cn.columnSpan.getOr(ec, 1)
+	rowSpan := cn.rowSpan.getOr(ec, 1)
 
-	childrenDirection := validateStringValue(replaceWithValuesUnsafe(n.InnerDirection, data, parentData, currentValueIndex, context.cache), []string{"column", "row"})
-	childrenJustify := validateStringValue(replaceWithValuesUnsafe(n.Justify, data, parentData, currentValueIndex, context.cache), []string{"start", "center", "end", "space-between", "space-evenly"})
-	childrenColumnAlign := validateStringValue(replaceWithValuesUnsafe(n.ChildrenColumnAlign, data, parentData, currentValueIndex, context.cache), []string{"left", "center", "right"})
-	childrenWrap := validateStringValue(replaceWithValuesUnsafe(n.ChildrenWrap, data, parentData, currentValueIndex, context.cache), []string{"wrap", "none"})
+	lineHeight := context.props.LineHeight // inherited
+	if v, ok := cn.lineHeight.lookup(ec); ok {
+		lineHeight = v.resolve(parentW, parentH, true)[0]
+	}
 
-	innerGap, _ := parseNValues(n.InnerGap, 1, context.size.W, context.size.H, data, parentData, currentValueIndex, true, false, context.cache)
+	rotation := cn.rotation.getOr(ec, unitValues{}).resolve(parentW, parentH, false)
 
-	rotation, _ := parseNValues(n.Rotation, 1, context.size.W, context.size.H, data, parentData, currentValueIndex, true, true, context.cache)
+	border := cn.border.getOr(ec, utils.Border{})
 
-	border, _ := parseBorderProperty(replaceWithValuesUnsafe(n.Border, data, parentData, currentValueIndex, context.cache))
+	offsetAnchors := cn.offset.getOr(ec, utils.AbsolutePosition{})
 
-	offsetAnchors := parseAnchors(n.Offset, data, parentData, currentValueIndex, context.cache)
+	grow := cn.grow.getOr(ec, 0)
+	shrink := cn.shrink.getOr(ec, 0)
 
-	if n.Text != "" {
+	if cn.text.isSet || len(cn.spans) > 0 {
 		childrenDirection = "row"
 	}
 
 	return CalculatedProperties{
-		Size:                   utils.Size{W: sz[0], H: sz[1]},
-		BkgColor:               backgroundColor,
-		FontColor:              fontColor,
-		ChildAlign:             "",
-		IsChildrenDirectionRow: childrenDirection == "row",
-		Justify:                childrenJustify,
-		ChildrenColumnAlign:    childrenColumnAlign,
-		IsWrappingEnabled:      childrenWrap == "wrap",
-		LineHeight:             lo.Ternary(n.LineHeight == "", context.props.LineHeight, lineHeight[0]),
-		Padding:                utils.TopRightBottomLeft{padding[0], padding[1], padding[2], padding[3]},
-		FontDescription:        fontDescription,
-		BorderRadius:           borderRadius,
-		AbsolutePosition:       anchors,
-		InnerGap:               innerGap[0],
-		Rotation:               rotation[0],
-		BkgImageSize:           lo.Ternary(bkgImageSize == "contain", BkgImageSizeContain, BkgImageSizeCover),
-		Border:                 border,
-		Offset:                 utils.TopRightBottomLeft{offsetAnchors.Top(), offsetAnchors.Right(), offsetAnchors.Bottom(), offsetAnchors.Left()},
+		Size:                    utils.Size{W: sz[0], H: sz[1]},
+		MinSize:                 minSize,
+		MaxSize:                 maxSize,
+		AspectRatio:             aspectRatio,
+		BkgColor:                backgroundColor,
+		FontColor:               fontColor,
+		ChildAlign:              childrenAlign,
+		AlignSelf:               alignSelf,
+		IsChildrenDirectionRow:  childrenDirection == "row",
+		IsChildrenDirectionGrid: childrenDirection == "grid",
+		Justify:                 childrenJustify,
+		ChildrenColumnAlign:     childrenColumnAlign,
+		IsWrappingEnabled:       childrenWrap == "wrap",
+		TextAlign:               textAlign,
+		TextTransform:           textTransform,
+		TextDecoration:          textDecoration,
+		TextStroke:              textStroke,
+		TextShadow:              textShadow,
+		MaxLines:                maxLines,
+		Ellipsis:                ellipsis,
+		GridColumns:             gridColumns,
+		GridRowGap:              gridGap[0],
+		GridColumnGap:           gridGap[1],
+		ColumnSpan:              columnSpan,
+		RowSpan:                 rowSpan,
+		LineHeight:              lineHeight,
+		Padding:                 utils.TopRightBottomLeft{padding[0], padding[1], padding[2], padding[3]},
+		Margin:                  utils.TopRightBottomLeft{margin[0], margin[1], margin[2], margin[3]},
+		FontDescription:         fontDescription,
+		IsFontSizeFit:           isFontSizeFit,
+		MinFontSize:             math.Max(1, minFontSize),
+		MaxFontSize:             maxFontSize,
+		LetterSpacingEm:         letterSpacingEm,
+		BorderRadius:            borderRadius,
+		IsOverflowHidden:        overflow == "hidden",
+		AbsolutePosition:        anchors,
+		InnerGap:                innerGap[0],
+		Rotation:                rotation[0],
+		BkgImageSize:            lo.Ternary(bkgImageSize == "contain", BkgImageSizeContain, BkgImageSizeCover),
+		Border:                  border,
+		Offset:                  utils.TopRightBottomLeft{offsetAnchors.Top(), offsetAnchors.Right(), offsetAnchors.Bottom(), offsetAnchors.Left()},
+		Grow:                    grow,
+		Shrink:                  shrink,
 	}
 }
 
-func parseAnchors(value string, data any, parentValue any, currentValueIndex int, cache *Cache) (result utils.AbsolutePosition) {
-	value = replaceWithValuesUnsafe(value, data, parentValue, currentValueIndex, cache)
+// parseAspectRatio parses ratio of width to height, e.g. "16/9" or "1.5"
+func parseAspectRatio(value string) (float64, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) > 2 {
+		return 0, fmt.Errorf("malformed aspect ratio \"%v\", expected width/height or number", value)
+	}
+
+	ratio, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err == nil && len(parts) == 2 {
+		var height float64
+		height, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		ratio /= height
+	}
+	if err != nil || ratio <= 0 || math.IsInf(ratio, 0) {
+		return 0, fmt.Errorf("malformed aspect ratio \"%v\", expected width/height or number", value)
+	}
+
+	return ratio, nil
+}
+
+// parseFactor parses not negative number, e.g. grow factor
+func parseFactor(value string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("malformed factor \"%v\", expected not negative number", value)
+	}
+	return f, nil
+}
+
+func parseString(value string) (string, error) {
+	return value, nil
+}
+
+func enumParser(options []string) func(value string) (string, error) {
+	return func(value string) (string, error) {
+		return validateStringValue(value, options), nil
+	}
+}
+
+func parseAnchors(value string) (result utils.AbsolutePosition, err error) {
 	tokens := strings.Fields(value)
 	for _, token := range tokens {
 		tokenParts := strings.Split(token, "/")
@@ -151,7 +276,7 @@ func parseAnchors(value string, data any, parentValue any, currentValueIndex int
 			result[3] = utils.AbsolutePos{Has: true, Offset: offset}
 		}
 	}
-	return result
+	return result, nil
 }
 
 func parseBorderProperty(value string) (res utils.Border, err error) {
@@ -196,6 +321,110 @@ func parseBorderProperty(value string) (res utils.Border, err error) {
 	return res, nil
 }
 
+// parseTextStroke parses width of stroke with optional color, e.g. "2 black"
+func parseTextStroke(value string) (res utils.TextStroke, err error) {
+	tokens := strings.Fields(value)
+	if len(tokens) == 0 {
+		return res, fmt.Errorf("text stroke is empty")
+	}
+
+	res.Width, err = strconv.ParseFloat(tokens[0], 64)
+	if err != nil || res.Width < 0 {
+		return res, fmt.Errorf("malformed text stroke width \"%v\", expected non-negative number", tokens[0])
+	}
+
+	if len(tokens) > 1 {
+		res.Color, err = parseColor(strings.Join(tokens[1:], " "))
+		if err != nil {
+			return res, fmt.Errorf("malformed text stroke color: %w", err)
+		}
+	}
+
+	return res, nil
+}
+
+// parseTextShadow parses offsets and blur of shadow with optional color, e.g. "1 2 4 rgba(0,0,0,0.5)".
+// Both offsets are required, and blur is zero if omitted.
+func parseTextShadow(value string) (res utils.TextShadow, err error) {
+	tokens := strings.Fields(value)
+
+	var numbers []float64
+	for len(tokens) > 0 && len(numbers) < 3 {
+		v, err := strconv.ParseFloat(tokens[0], 64)
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, v)
+		tokens = tokens[1:]
+	}
+
+	if len(numbers) < 2 {
+		return res, fmt.Errorf("malformed text shadow \"%v\", expected offsets with optional blur and color", value)
+	}
+	res.OffsetX, res.OffsetY = numbers[0], numbers[1]
+	if len(numbers) == 3 {
+		if numbers[2] < 0 {
+			return res, fmt.Errorf("text shadow blur can't be negative")
+		}
+		res.Blur = numbers[2]
+	}
+
+	if len(tokens) > 0 {
+		res.Color, err = parseColor(strings.Join(tokens, " "))
+		if err != nil {
+			return res, fmt.Errorf("malformed text shadow color: %w", err)
+		}
+	}
+
+	return res, nil
+}
+
+// parseTextDecoration parses lines with optional color and thickness, e.g. "underline red 2"
+func parseTextDecoration(value string) (res utils.TextDecoration, err error) {
+	var thicknessIsSet bool
+	var colorIsSet bool
+
+	for _, t := range strings.Fields(value) {
+		switch t {
+		case "none":
+			continue
+		case "underline":
+			res.Lines |= utils.TextDecorationUnderline
+			continue
+		case "line-through":
+			res.Lines |= utils.TextDecorationLineThrough
+			continue
+		case "overline":
+			res.Lines |= utils.TextDecorationOverline
+			continue
+		}
+
+		thickness, err := strconv.ParseFloat(t, 64)
+		if err == nil && thickness >= 0 {
+			if thicknessIsSet {
+				return res, fmt.Errorf("trying to specify text decoration thickness %v, but thickness is already set", thickness)
+			}
+			thicknessIsSet = true
+			res.Thickness = thickness
+			continue
+		}
+
+		c, err := parseColor(t)
+		if err == nil {
+			if colorIsSet {
+				return res, fmt.Errorf("trying to specify text decoration color %v, but color is already set", c)
+			}
+			colorIsSet = true
+			res.Color = c
+			continue
+		}
+
+		return res, fmt.Errorf("unknown token %v in text decoration property", t)
+	}
+
+	return res, nil
+}
+
 func prepareParsedValue(value float64, isVertical bool, unit int, parentWidth float64, parentHeight float64) float64 {
 	switch unit {
 	case unitAbs:
@@ -221,15 +450,27 @@ var parseValueRegex = regexp.MustCompile(`(?i)(-?\d+(\.\d+)?)(%|w|h|)`)
 var valuesEmptyErr = errors.New("values empty")
 var valuesParseErr = errors.New("values format not correct")
 
-func parseNValues(str string, max int, parentWidth float64, parentHeight float64, data any, parentData any, currentValueIndex int, relativeToWidth bool, allowNegative bool, cache *Cache) (utils.FourValues, error) {
-	var result utils.FourValues
+// unitValues are up to four parsed numbers with units,
+// that are resolved to absolute values when size of parent is known
+type unitValues struct {
+	values [4]float64
+	units  [4]int
+	count  int
+}
+
+func unitValuesParser(max int, allowNegative bool) func(str string) (unitValues, error) {
+	return func(str string) (unitValues, error) {
+		return parseUnitValues(str, max, allowNegative)
+	}
+}
+
+func parseUnitValues(str string, max int, allowNegative bool) (unitValues, error) {
+	var result unitValues
 
 	if str == "" {
 		return result, valuesEmptyErr
 	}
 
-	str = replaceWithValuesUnsafe(str, data, parentData, currentValueIndex, cache)
-
 	matches := parseValueRegex.FindAllStringSubmatch(str, -1)
 	if len(matches) > max || len(matches) == 0 {
 		return result, valuesParseErr
@@ -255,30 +496,38 @@ func parseNValues(str string, max int, parentWidth float64, parentHeight float64
 			unit = unitHeight
 		}
 
-		isVertical := i%2 == 1
-		if max == 1 {
-			isVertical = !relativeToWidth
-		}
+		result.values[i] = val
+		result.units[i] = unit
+	}
+	result.count = len(matches)
+
+	return result, nil
+}
 
-		result[i] = prepareParsedValue(val, isVertical, unit, parentWidth, parentHeight)
+// resolve returns absolute values. Every second value is vertical, so percents are relative to parent height,
+// and if isFirstVertical is true, the first value is vertical too (used for single value properties like height).
+// Missing values are filled the same way as css does for paddings.
+func (uv unitValues) resolve(parentWidth float64, parentHeight float64, isFirstVertical bool) utils.FourValues {
+	var result utils.FourValues
+
+	for i := 0; i < uv.count; i++ {
+		isVertical := (i%2 == 1) != isFirstVertical
+		result[i] = prepareParsedValue(uv.values[i], isVertical, uv.units[i], parentWidth, parentHeight)
 	}
 
-	if len(matches) == 1 {
+	switch uv.count {
+	case 1:
 		result[1] = result[0]
 		result[2] = result[0]
 		result[3] = result[0]
-	}
-
-	if len(matches) == 2 {
+	case 2:
 		result[2] = result[0]
 		result[3] = result[1]
-	}
-
-	if len(matches) == 3 {
+	case 3:
 		result[3] = result[1]
 	}
 
-	return result, nil
+	return result
 }
 
 var hexRegex = regexp.MustCompile(`^0x([a-fA-F0-9]{6})([a-fA-F0-9]{2})?$`)
@@ -336,36 +585,56 @@ func validateStringValue(v string, options []string) string {
 	return options[0]
 }
 
-func parseFontString(prop string, fd fonts.FaceDescription, parentWidth float64, parentHeight float64, data any, parentData any, currentValueIndex int, cache *Cache) fonts.FaceDescription {
-	if prop == "" {
-		return fd
-	}
+// fontShorthand is parsed font property, e.g. "Roboto 16 700 italic".
+// First number is size, second is weight.
+type fontShorthand struct {
+	family   string
+	style    font.Style
+	hasStyle bool
+	size     unitValues
+	weight   unitValues
+}
 
-	prop = replaceWithValuesUnsafe(prop, data, parentData, currentValueIndex, cache)
-	prop = strings.ReplaceAll(prop, ",", " ")
+func parseFontShorthand(prop string) (fontShorthand, error) {
+	var fs fontShorthand
 
-	isSizeSet := false
+	prop = strings.ReplaceAll(prop, ",", " ")
 
 	tokens := strings.Fields(prop)
 	for _, token := range tokens {
-		v, err := parseNValues(token, 1, parentWidth, parentHeight, data, parentData, currentValueIndex, true, false, cache)
+		v, err := parseUnitValues(token, 1, false)
 		if err != nil {
 			if token == "italic" {
-				fd.Style = font.StyleItalic
+				fs.style, fs.hasStyle = font.StyleItalic, true
 			} else if token == "normal" {
-				fd.Style = font.StyleNormal
+				fs.style, fs.hasStyle = font.StyleNormal, true
 			} else {
-				fd.Family = token
+				fs.family = token
 			}
 		} else {
-			if !isSizeSet {
-				fd.Size = v[0]
-				isSizeSet = true
+			if fs.size.count == 0 {
+				fs.size = v
 			} else {
-				fd.Weight = int(v[0])
+				fs.weight = v
 			}
 		}
 	}
 
+	return fs, nil
+}
+
+func (fs fontShorthand) apply(fd fonts.FaceDescription, parentWidth float64, parentHeight float64) fonts.FaceDescription {
+	if fs.family != "" {
+		fd.Family = fs.family
+	}
+	if fs.hasStyle {
+		fd.Style = fs.style
+	}
+	if fs.size.count > 0 {
+		fd.Size = fs.size.resolve(parentWidth, parentHeight, false)[0]
+	}
+	if fs.weight.count > 0 {
+		fd.Weight = int(fs.weight.resolve(parentWidth, parentHeight, false)[0])
+	}
 	return fd
 }
@@ -82,3 +82,96 @@ func TestParseBorderProperty(t *testing.T) {
 		})
 	}
 }
+
+func TestUnitValues(t *testing.T) {
+	tests := []struct {
+		input           string
+		max             int
+		isFirstVertical bool
+		expected        utils.FourValues
+	}{
+		{input: "10", max: 4, expected: utils.FourValues{10, 10, 10, 10}},
+		{input: "10 20", max: 4, expected: utils.FourValues{10, 20, 10, 20}},
+		{input: "10 20 30", max: 4, expected: utils.FourValues{10, 20, 30, 20}},
+		{input: "50% 50%", max: 2, expected: utils.FourValues{100, 50, 100, 50}},
+		{input: "0.5w 0.5h", max: 2, expected: utils.FourValues{100, 50, 100, 50}},
+		{input: "10%", max: 1, expected: utils.FourValues{20, 20, 20, 20}},
+		{input: "10%", max: 1, isFirstVertical: true, expected: utils.FourValues{10, 10, 10, 10}},
+		{input: "-10", max: 1, expected: utils.FourValues{10, 10, 10, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			uv, err := parseUnitValues(tt.input, tt.max, false)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, uv.resolve(200, 100, tt.isFirstVertical))
+		})
+	}
+
+	_, err := parseUnitValues("10 20", 1, false)
+	assert.Error(t, err)
+}
+
+func TestParseAspectRatio(t *testing.T) {
+	ratio, err := parseAspectRatio("16/9")
+	assert.NoError(t, err)
+	assert.InDelta(t, 16.0/9.0, ratio, 1e-9)
+
+	ratio, err = parseAspectRatio("1.5")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, ratio)
+
+	for _, v := range []string{"", "16/0", "0", "-1", "1/2/3", "a/b"} {
+		_, err = parseAspectRatio(v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestParseTextDecoration(t *testing.T) {
+	d, err := parseTextDecoration("underline line-through red 1.5")
+	assert.NoError(t, err)
+	assert.Equal(t, utils.TextDecoration{
+		Lines:     utils.TextDecorationUnderline | utils.TextDecorationLineThrough,
+		Color:     color.RGBA{R: 255, A: 255},
+		Thickness: 1.5,
+	}, d)
+
+	d, err = parseTextDecoration("none")
+	assert.NoError(t, err)
+	assert.Equal(t, utils.TextDecoration{}, d)
+
+	for _, v := range []string{"underline wavy", "underline 1 2", "underline red blue"} {
+		_, err = parseTextDecoration(v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestParseTextStroke(t *testing.T) {
+	s, err := parseTextStroke("2 black")
+	assert.NoError(t, err)
+	assert.Equal(t, utils.TextStroke{Width: 2, Color: color.RGBA{A: 255}}, s)
+
+	s, err = parseTextStroke("1.5")
+	assert.NoError(t, err)
+	assert.Equal(t, utils.TextStroke{Width: 1.5}, s)
+
+	for _, v := range []string{"", "black", "-1 black", "2 wavy"} {
+		_, err = parseTextStroke(v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestParseTextShadow(t *testing.T) {
+	s, err := parseTextShadow("1 2 4 rgba(0, 0, 0, 0.5)")
+	assert.NoError(t, err)
+	assert.Equal(t, utils.TextShadow{OffsetX: 1, OffsetY: 2, Blur: 4, Color: color.RGBA{A: 127}}, s)
+
+	s, err = parseTextShadow("-1 2")
+	assert.NoError(t, err)
+	assert.Equal(t, utils.TextShadow{OffsetX: -1, OffsetY: 2}, s)
+
+	for _, v := range []string{"", "1 red", "1 2 -4", "1 2 3 4", "1 2 wavy"} {
+		_, err = parseTextShadow(v)
+		assert.Error(t, err, v)
+	}
+}
@@ -2,55 +2,199 @@ package layout
 
 import (
 	"fmt"
-	"github.com/antonmedv/expr"
-	"github.com/godknowsiamgood/decorender/internal/utils"
+	"github.com/antonmedv/expr/vm"
 	"reflect"
 	"strconv"
-	"strings"
+	"sync"
 )
 
-func replaceWithValuesUnsafe(str string, value any, parentValue any, valueIndex int, cache *Cache) string {
-	v, _ := replaceWithValues(str, value, parentValue, valueIndex, cache)
-	return v
+var vmPool = sync.Pool{
+	New: func() any {
+		return &vm.VM{}
+	},
 }
 
-func replaceWithValues(str string, value any, parentValue any, valueIndex int, cache *Cache) (string, error) {
-	if !strings.HasPrefix(str, "~") {
-		return str, nil
+// evalContext is environment of expressions for current iteration of node.
+// Nodes without forEach share context of parent.
+type evalContext struct {
+	value       any
+	parentValue any
+	index       int
+
+	// params are values of templated component params, visible to node that uses component and its children
+	params map[string]any
+
+	env    any
+	hasEnv bool
+
+	// Results of expressions and contexts of forEach iterations of children are kept,
+	// so nodes that are laid out again with sizes forced by parent are not evaluated again
+	results    map[*vm.Program]evalResult
+	iterations map[*compiledNode][]*evalContext
+}
+
+type evalResult struct {
+	value any
+	err   error
+}
+
+func (ec *evalContext) run(program *vm.Program) (any, error) {
+	if r, ok := ec.results[program]; ok {
+		return r.value, r.err
 	}
 
-	str = strings.TrimLeft(str, "~")
+	if !ec.hasEnv {
+		ec.env = ec.getEnv()
+		ec.hasEnv = true
+	}
 
-	cache.programsMx.Lock()
-	defer cache.programsMx.Unlock()
+	v := vmPool.Get().(*vm.VM)
+	value, err := v.Run(program, ec.env)
+	vmPool.Put(v)
 
-	var err error
+	if ec.results == nil {
+		ec.results = make(map[*vm.Program]evalResult)
+	}
+	ec.results[program] = evalResult{value: value, err: err}
 
-	key := utils.HashDJB2(str)
-	program, _ := cache.programs[key]
-	if program == nil {
-		program, err = expr.Compile(str)
-		if err != nil {
-			return str, err
+	return value, err
+}
+
+func (ec *evalContext) getEnv() any {
+	if len(ec.params) == 0 {
+		if ec.parentValue == nil {
+			return ec.value
 		}
-		cache.programs[key] = program
+		return map[string]any{"value": ec.value, "parent": ec.parentValue, "index": ec.index}
 	}
 
-	var result any
-	if parentValue == nil {
-		result, err = expr.Run(program, value)
+	// Same fields as type returned by exprScope.env
+	var env map[string]any
+	if ec.parentValue == nil {
+		env = getFields(ec.value)
 	} else {
-		result, err = expr.Run(program, map[string]any{"value": value, "parent": parentValue, "index": valueIndex})
+		env = map[string]any{"value": ec.value, "parent": ec.parentValue, "index": ec.index}
 	}
-	if err != nil {
-		return str, err
+	for name, value := range ec.params {
+		env[name] = value
+	}
+	return env
+}
+
+// getFields returns copy of map or exported fields of struct
+func getFields(value any) map[string]any {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
 	}
 
-	switch result.(type) {
+	fields := make(map[string]any)
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String {
+			for it := v.MapRange(); it.Next(); {
+				fields[it.Key().String()] = it.Value().Interface()
+			}
+		}
+	case reflect.Struct:
+		for _, f := range reflect.VisibleFields(v.Type()) {
+			if !f.IsExported() || f.Anonymous {
+				continue
+			}
+			if fv, err := v.FieldByIndexErr(f.Index); err == nil {
+				fields[exprFieldName(f)] = fv.Interface()
+			}
+		}
+	}
+	return fields
+}
+
+// getIterations returns contexts of forEach iterations of child node in order of layout,
+// with params of node bound
+func (ec *evalContext) getIterations(cn *compiledNode) ([]*evalContext, error) {
+	if iterations, ok := ec.iterations[cn]; ok {
+		return iterations, nil
+	}
+
+	iterations := []*evalContext{ec}
+	if cn.forEach.isSet {
+		forEach, err := cn.forEach.get(ec)
+		if err != nil {
+			return nil, err
+		}
+
+		iterations = nil
+		err = RunForEach(ec.value, forEach, func(currentValue any, iteratorValue any, currentValueIndex int) error {
+			if iteratorValue == nil {
+				iteratorValue = ec.parentValue
+			}
+			iterations = append(iterations, &evalContext{value: currentValue, parentValue: iteratorValue, index: currentValueIndex, params: ec.params})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cn.params) > 0 {
+		for i, iteration := range iterations {
+			bound, err := iteration.bindParams(cn.params)
+			if err != nil {
+				return nil, err
+			}
+			iterations[i] = bound
+		}
+	}
+
+	if ec.iterations == nil {
+		ec.iterations = make(map[*compiledNode][]*evalContext)
+	}
+	ec.iterations[cn] = iterations
+
+	return iterations, nil
+}
+
+// bindParams evaluates params in this context and returns context with them visible
+func (ec *evalContext) bindParams(params []compiledParam) (*evalContext, error) {
+	bound := &evalContext{value: ec.value, parentValue: ec.parentValue, index: ec.index, params: make(map[string]any, len(ec.params)+len(params))}
+	for name, value := range ec.params {
+		bound.params[name] = value
+	}
+	for _, p := range params {
+		value, err := ec.run(p.program)
+		if err != nil {
+			return nil, fmt.Errorf("param %v: %w", p.name, err)
+		}
+		bound.params[p.name] = value
+	}
+	return bound, nil
+}
+
+func stringify(v any) string {
+	switch s := v.(type) {
 	case string:
-		return result.(string), nil
+		return s
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// isTruthy returns false for false, nil, zero numbers and empty strings, slices and maps
+func isTruthy(v any) bool {
+	if v == nil {
+		return false
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() > 0
+	case reflect.Ptr, reflect.Interface:
+		return !rv.IsNil()
 	default:
-		return fmt.Sprintf("%v", result), nil
+		return !rv.IsZero()
 	}
 }
 
@@ -1,9 +1,13 @@
 package layout
 
 import (
+	"fmt"
 	"github.com/godknowsiamgood/decorender/internal/fonts"
 	"github.com/godknowsiamgood/decorender/internal/utils"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
 	"golang.org/x/text/unicode/norm"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -12,37 +16,112 @@ const hyphen = '-'
 const hyphenString = string(hyphen)
 
 func spitTextToNodes(nodes *Nodes, text string, context layoutPhaseContext) float64 {
+	appendTextNodes(nodes, newTextNodes(text, context.props, context.level+1))
+	return fonts.MeasureTextWidth(" ", context.props.FontDescription)
+}
+
+// spitSpansToNodes splits texts of all spans into one paragraph. Every span has its own properties
+// that are inherited from text node. Spans without whitespace between them are glued like hyphenated word.
+func spitSpansToNodes(nodes *Nodes, spans []compiledNode, context layoutPhaseContext, parentEC *evalContext) (float64, error) {
+	skippedByElse, err := getSkippedByElse(spans, parentEC)
+	if err != nil {
+		return 0, err
+	}
+
+	var paragraph []Node
+	isGlued := false
+	for i := range spans {
+		if skippedByElse != nil && skippedByElse[i] {
+			continue
+		}
+
+		span := &spans[i]
+		err = runNodeForEach(span, parentEC, func(ec *evalContext) error {
+			if span.ifCond.isSet {
+				isVisible, err := span.ifCond.get(ec)
+				if err != nil || !isVisible {
+					return err
+				}
+			}
+
+			text, err := span.text.get(ec)
+			if err != nil {
+				return err
+			}
+
+			props := calculateProperties(span, context, ec)
+			text = transformText(text, props.TextTransform, context.locale)
+
+			tokens := newTextNodes(text, props, context.level+1)
+			if len(tokens) == 0 {
+				return nil
+			}
+
+			if isGlued && len(paragraph) > 0 && strings.TrimLeftFunc(text, unicode.IsSpace) == text {
+				paragraph[len(paragraph)-1].TextHasHyphenAtEnd = true
+			}
+			isGlued = strings.TrimRightFunc(text, unicode.IsSpace) == text
+
+			paragraph = append(paragraph, tokens...)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	appendTextNodes(nodes, paragraph)
+	return fonts.MeasureTextWidth(" ", context.props.FontDescription), nil
+}
+
+// newTextNodes returns nodes of words of text in order of reading
+func newTextNodes(text string, props CalculatedProperties, level int) []Node {
 	tokens := splitText(text)
 
 	var height float64
-	if context.props.LineHeight == -1 {
-		height = float64(context.props.FontDescription.Size) * 1.2
+	if props.LineHeight == -1 {
+		height = float64(props.FontDescription.Size) * 1.2
 	} else {
-		height = context.props.LineHeight
+		height = props.LineHeight
 	}
 
-	for i := len(tokens) - 1; i >= 0; i-- {
-		t := tokens[i]
+	baseline := height
+	if face, err := fonts.GetFontFace(props.FontDescription); err == nil {
+		baseline = fonts.GetFontFaceBaseLineOffset(face, height)
+	}
 
-		node := Node{
+	result := make([]Node, len(tokens))
+	for i, t := range tokens {
+		result[i] = Node{
 			Size: utils.Size{
-				W: fonts.MeasureTextWidth(t, context.props.FontDescription),
+				W: fonts.MeasureTextWidth(t, props.FontDescription),
 				H: height,
 			},
 			Props: CalculatedProperties{
-				FontColor:       context.props.FontColor,
-				FontDescription: context.props.FontDescription,
-				LineHeight:      context.props.LineHeight,
+				FontColor:       props.FontColor,
+				FontDescription: props.FontDescription,
+				LineHeight:      props.LineHeight,
+				TextDecoration:  props.TextDecoration,
+				TextStroke:      props.TextStroke,
+				TextShadow:      props.TextShadow,
+				// Words of different fonts in one row are aligned by their baselines
+				AlignSelf: "baseline",
 			},
 			Text:               t,
+			Baseline:           baseline,
 			TextHasHyphenAtEnd: strings.HasSuffix(t, hyphenString),
-			Level:              context.level + 1,
+			Level:              level,
 		}
-
-		*nodes = append(*nodes, node)
 	}
 
-	return fonts.MeasureTextWidth(" ", context.props.FontDescription)
+	return result
+}
+
+// appendTextNodes appends nodes of words in reverse order like all children
+func appendTextNodes(nodes *Nodes, words []Node) {
+	for i := len(words) - 1; i >= 0; i-- {
+		*nodes = append(*nodes, words[i])
+	}
 }
 
 func splitText(input string) []string {
@@ -76,30 +155,234 @@ func splitText(input string) []string {
 	return result
 }
 
-// Little tricky method to merge texts nodes in rows into one node per row for optimized rendering
-func mergeTextNodes(nodes *Nodes, level int, from int) {
+// Little tricky method to merge texts nodes in rows into one node per row for optimized rendering.
+// Only adjacent words of the same style are merged, so row of spans has one node per span.
+// Words of first separateRows rows are kept as separate nodes, so whitespaces between them can be justified.
+func mergeTextNodes(nodes *Nodes, level int, from int, separateRows int) {
 	var sb strings.Builder
 
-	originalFrom := from
-	index := 0
-	nodes.IterateRowsReverse(level, from, func(rowIndex int) {
-		sb.Reset()
-		var last *Node
-		nodes.IterateRow(level, from, rowIndex, func(n *Node) {
-			if last != nil && !last.TextHasHyphenAtEnd {
-				sb.WriteString(" ")
+	merged := from
+	for i := from; i < len(*nodes); {
+		// Words are stored from last to first, so run of words of the same style starts at j-1 and ends at i
+		j := i + 1
+		if (*nodes)[i].RowIndex >= separateRows {
+			for j < len(*nodes) && isSameTextStyle(&(*nodes)[i], &(*nodes)[j]) {
+				j++
 			}
-			sb.WriteString(n.Text)
-			last = n
-			from++
-		})
-		last.Text = sb.String()
-		last.Size.W = fonts.MeasureTextWidth(last.Text, last.Props.FontDescription)
-		last.InRowIndex = 0
+		}
+
+		n := (*nodes)[i]
+		if j-i > 1 {
+			sb.Reset()
+			for k := j - 1; k >= i; k-- {
+				sb.WriteString((*nodes)[k].Text)
+				if k > i && !(*nodes)[k].TextHasHyphenAtEnd {
+					sb.WriteString(" ")
+				}
+			}
+			n.Text = sb.String()
+			n.Size.W = fonts.MeasureTextWidth(n.Text, n.Props.FontDescription)
+			n.InRowIndex = (*nodes)[j-1].InRowIndex
+		}
+
+		(*nodes)[merged] = n
+		merged++
+		i = j
+	}
+
+	*nodes = (*nodes)[0:merged]
+}
+
+// isSameTextStyle checks if words are in the same row and are rendered the same way, so they can be merged
+func isSameTextStyle(a *Node, b *Node) bool {
+	return a.Level == b.Level &&
+		a.RowIndex == b.RowIndex &&
+		a.Props.FontDescription == b.Props.FontDescription &&
+		a.Props.FontColor == b.Props.FontColor &&
+		a.Props.TextDecoration == b.Props.TextDecoration &&
+		a.Props.TextStroke == b.Props.TextStroke &&
+		a.Props.TextShadow == b.Props.TextShadow &&
+		a.Size.H == b.Size.H
+}
+
+// truncateTextRows leaves only first maxLines rows of merged text nodes. The last node of the last visible row
+// gets ellipsis if some rows were dropped, or if row is wider than available width, e.g. when wrapping is disabled.
+func truncateTextRows(nodes *Nodes, level int, from int, props *CalculatedProperties, textWhitespaceWidth float64, width float64) {
+	maxLines := props.MaxLines
+
+	var dropped int
+	var lastNode *Node
+	for i := from; i < len(*nodes); i++ {
+		n := &(*nodes)[i]
+		if n.Level != level {
+			continue
+		}
+		if n.RowIndex >= maxLines {
+			dropped++
+		} else if lastNode == nil || n.RowIndex > lastNode.RowIndex {
+			lastNode = n
+		}
+	}
+
+	if lastNode == nil {
+		return
+	}
+
+	rowWidth, _ := nodes.RowTotalWidth(level, from, lastNode.RowIndex, textWhitespaceWidth, props.InnerGap)
+	if dropped > 0 || rowWidth > width {
+		lastWidth := width - (rowWidth - lastNode.Size.W)
+		lastNode.Text = fitTextWithEllipsis(lastNode.Text, props.Ellipsis, lastWidth, lastNode.Props.FontDescription)
+		lastNode.Size.W = fonts.MeasureTextWidth(lastNode.Text, lastNode.Props.FontDescription)
+	}
 
-		(*nodes)[originalFrom+index] = *last
-		index++
+	// Merged rows are stored from last to first, so dropped rows are at the beginning
+	if dropped > 0 {
+		n := copy((*nodes)[from:], (*nodes)[from+dropped:])
+		*nodes = (*nodes)[0 : from+n]
+	}
+}
+
+// fitTextWithEllipsis returns the longest beginning of text followed by ellipsis that fits width
+func fitTextWithEllipsis(text string, ellipsis string, width float64, fd fonts.FaceDescription) string {
+	runes := []rune(text)
+	withEllipsis := func(count int) string {
+		return strings.TrimRightFunc(string(runes[:count]), unicode.IsSpace) + ellipsis
+	}
+
+	// Binary search of rune count, ellipsis alone is left if nothing fits
+	low, high := 0, len(runes)
+	for low < high {
+		mid := (low + high + 1) / 2
+		if fonts.MeasureTextWidth(withEllipsis(mid), fd) <= width {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+
+	return withEllipsis(low)
+}
+
+// getTextSeparateRows returns how many first rows of text keep separate words, that is all rows
+// except the last visible one with justify text align, and none otherwise
+func getTextSeparateRows(nodes Nodes, level int, from int, props *CalculatedProperties) int {
+	if props.TextAlign != "justify" {
+		return 0
+	}
+
+	rowsCount := 0
+	nodes.IterateRows(level, from, func(_ int, _ *Node) {
+		rowsCount++
 	})
+	if props.MaxLines > 0 && rowsCount > props.MaxLines {
+		rowsCount = props.MaxLines
+	}
+
+	return rowsCount - 1
+}
+
+// getTextJustify returns justify of rows of text for text align
+func getTextJustify(props *CalculatedProperties) string {
+	switch props.TextAlign {
+	case "center":
+		return "center"
+	case "right":
+		return "end"
+	case "left", "justify":
+		return "start"
+	}
+	return props.Justify
+}
 
-	*nodes = (*nodes)[0 : originalFrom+index]
+// getTextWordGap returns extra width of every whitespace in justified row of text, so row takes whole width.
+// The last row is not justified.
+func getTextWordGap(nodes Nodes, level int, from int, rowIndex int, props *CalculatedProperties, rowWidth float64, width float64) float64 {
+	if props.TextAlign != "justify" || rowIndex >= getTextSeparateRows(nodes, level, from, props) {
+		return 0
+	}
+
+	whitespacesCount := 0
+	var last *Node
+	nodes.IterateRow(level, from, rowIndex, func(cn *Node) {
+		if last != nil && !last.TextHasHyphenAtEnd {
+			whitespacesCount++
+		}
+		last = cn
+	})
+
+	if whitespacesCount == 0 || rowWidth >= width {
+		return 0
+	}
+
+	return (width - rowWidth) / float64(whitespacesCount)
+}
+
+// setTextDecorationWidths makes decorations of words and spans in row continuous
+func setTextDecorationWidths(nodes Nodes, level int, from int) {
+	nodes.IterateRows(level, from, func(rowIndex int, _ *Node) {
+		var prev *Node
+		nodes.IterateRow(level, from, rowIndex, func(cn *Node) {
+			cn.TextDecorationWidth = cn.Size.W
+			if prev != nil && prev.Props.TextDecoration.Lines != 0 && prev.Props.TextDecoration == cn.Props.TextDecoration {
+				prev.TextDecorationWidth = cn.Pos.Left - prev.Pos.Left
+			}
+			prev = cn
+		})
+	})
+}
+
+// parseMaxLines parses maximum number of visible rows of text
+func parseMaxLines(value string) (int, error) {
+	lines, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || lines < 1 {
+		return 0, fmt.Errorf("malformed max lines \"%v\", expected positive integer", value)
+	}
+	return lines, nil
+}
+
+// transformText changes case of text with rules of locale
+func transformText(text string, transform string, locale language.Tag) string {
+	switch transform {
+	case "uppercase":
+		return cases.Upper(locale).String(text)
+	case "lowercase":
+		return cases.Lower(locale).String(text)
+	case "capitalize":
+		return cases.Title(locale, cases.NoLower).String(text)
+	}
+	return text
+}
+
+// letterSpacing is parsed letterSpacing property, in pixels or in em, that is font size
+type letterSpacing struct {
+	value float64
+	isEm  bool
+}
+
+// parseFontFeatures checks OpenType features, e.g. "tnum -liga", and returns them normalized,
+// as they are part of font description and are compared with features of other texts
+func parseFontFeatures(value string) (string, error) {
+	if _, err := fonts.ParseFontFeatures(value); err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(strings.ReplaceAll(value, ",", " ")), " "), nil
+}
+
+// parseLetterSpacing parses spacing between letters, e.g. "2" or "0.1em"
+func parseLetterSpacing(value string) (letterSpacing, error) {
+	value = strings.TrimSpace(value)
+	number, isEm := strings.TrimSuffix(value, "em"), strings.HasSuffix(value, "em")
+
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return letterSpacing{}, fmt.Errorf("malformed letter spacing \"%v\", expected number with optional em unit", value)
+	}
+	return letterSpacing{value: v, isEm: isEm}, nil
+}
+
+func (ls letterSpacing) resolve(fontSize float64) float64 {
+	if ls.isEm {
+		return ls.value * fontSize
+	}
+	return ls.value
 }
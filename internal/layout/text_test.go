@@ -0,0 +1,146 @@
+package layout
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestTransformText(t *testing.T) {
+	assert.Equal(t, "HELLO, WORLD", transformText("Hello, world", "uppercase", language.English))
+	assert.Equal(t, "hello, world", transformText("Hello, World", "lowercase", language.English))
+	assert.Equal(t, "Hello, WORLD", transformText("hello, WORLD", "capitalize", language.English))
+	assert.Equal(t, "Hello", transformText("Hello", "none", language.English))
+
+	// Rules of locale are used
+	assert.Equal(t, "İSTANBUL", transformText("istanbul", "uppercase", language.Turkish))
+}
+
+func TestParseLetterSpacing(t *testing.T) {
+	ls, err := parseLetterSpacing("2")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, ls.resolve(10))
+
+	ls, err = parseLetterSpacing("-0.1em")
+	assert.NoError(t, err)
+	assert.Equal(t, -2.0, ls.resolve(20))
+
+	for _, v := range []string{"", "em", "2px"} {
+		_, err = parseLetterSpacing(v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestParseFontFeatures(t *testing.T) {
+	features, err := parseFontFeatures(" tnum,-liga  ss01=2 ")
+	assert.NoError(t, err)
+	assert.Equal(t, "tnum -liga ss01=2", features)
+
+	_, err = parseFontFeatures("tabular")
+	assert.Error(t, err)
+}
+
+func TestMaxLines(t *testing.T) {
+	// texts returns texts of nodes of text rows, and width of the widest row
+	texts := func(nodes Nodes) (texts []string, width float64) {
+		for i := len(nodes) - 1; i >= 0; i-- {
+			if n := nodes[i]; n.Text != "" {
+				texts = append(texts, n.Text)
+				width = math.Max(width, n.Pos.Left+n.Size.W)
+			}
+		}
+		return texts, width
+	}
+
+	nodes := layoutTemplate(t, `
+width: 40
+font: 10
+text: aaa bbb ccc ddd eee fff ggg hhh`)
+	rows, _ := texts(nodes)
+	assert.Equal(t, []string{"aaa bbb", "ccc ddd", "eee fff", "ggg hhh"}, rows)
+
+	// Dropped rows are marked with ellipsis
+	nodes = layoutTemplate(t, `
+width: 40
+font: 10
+lineHeight: 10
+maxLines: 2
+text: aaa bbb ccc ddd eee fff ggg hhh`)
+	rows, _ = texts(nodes)
+	assert.Equal(t, []string{"aaa bbb", "ccc dd…"}, rows)
+	assert.Equal(t, 20.0, nodes[len(nodes)-1].Size.H)
+
+	// Row without wrapping is truncated to available width
+	nodes = layoutTemplate(t, `
+size: 40 100
+inner:
+  - font: 10
+    innerWrap: none
+    maxLines: 1
+    text: aaa bbb ccc ddd eee fff ggg hhh`)
+	rows, width := texts(nodes)
+	assert.Len(t, rows, 1)
+	assert.True(t, strings.HasPrefix(rows[0], "aaa") && strings.HasSuffix(rows[0], "…"), rows[0])
+	assert.LessOrEqual(t, width, 40.0)
+
+	// Row without wrapping overflows without limit
+	nodes = layoutTemplate(t, `
+size: 40 100
+inner:
+  - font: 10
+    innerWrap: none
+    text: aaa bbb ccc ddd eee fff ggg hhh`)
+	rows, width = texts(nodes)
+	assert.Equal(t, []string{"aaa bbb ccc ddd eee fff ggg hhh"}, rows)
+	assert.Greater(t, width, 40.0)
+
+	// First word is wider than box, it takes the first row instead of leaving it empty,
+	// so rows are counted from it and the last visible one gets ellipsis
+	for _, tt := range []struct {
+		template string
+		rows     int
+	}{
+		{"text: Total long text here\nmaxLines: 1", 1},
+		{"text: Total long text here\nmaxLines: 2", 2},
+		{"spans:\n  - text: \"Total \"\n  - text: long text here\nmaxLines: 1", 1},
+		{"text: Total long text here\ntextAlign: justify\nmaxLines: 2", 2},
+	} {
+		nodes = layoutTemplate(t, "width: 30\nfontSize: 16\nlineHeight: 20\n"+tt.template)
+		rows, _ = texts(nodes)
+		if assert.Len(t, rows, tt.rows, tt.template) {
+			assert.True(t, strings.HasPrefix(rows[0], "To"), tt.template)
+			assert.True(t, strings.HasSuffix(rows[len(rows)-1], "…"), tt.template)
+		}
+		assert.Equal(t, float64(tt.rows*20), nodes[len(nodes)-1].Size.H, tt.template)
+	}
+}
+
+func TestSpansGluing(t *testing.T) {
+	// "$42" and "," are glued, so they are wrapped together though "$42" alone fits the first row
+	nodes := layoutTemplate(t, `
+width: 42
+font: 10
+spans:
+  - text: "Total "
+  - text: $42
+    fontWeight: 700
+  - text: ", only today"`)
+
+	var price, comma *Node
+	for i := range nodes {
+		if nodes[i].Text == "$42" {
+			price = &nodes[i]
+		} else if strings.HasPrefix(nodes[i].Text, ",") {
+			comma = &nodes[i]
+		}
+	}
+	if assert.NotNil(t, price) && assert.NotNil(t, comma) {
+		assert.Equal(t, 1, price.RowIndex)
+		assert.Equal(t, 1, comma.RowIndex)
+		assert.True(t, price.TextHasHyphenAtEnd)
+		assert.InDelta(t, price.Pos.Left+price.Size.W, comma.Pos.Left, 0.001)
+	}
+}
@@ -16,23 +16,49 @@ const (
 
 type CalculatedProperties struct {
 	Size                   utils.Size
+	MinSize                utils.Size
+	MaxSize                utils.Size // -1 means no constraint
+	AspectRatio            float64    // 0 means no ratio
 	BkgColor               color.RGBA
 	FontColor              color.RGBA
 	FontDescription        fonts.FaceDescription
+	IsFontSizeFit          bool
+	MinFontSize            float64
+	MaxFontSize            float64 // -1 means height of content box
+	LetterSpacingEm        float64 // 0 means spacing in pixels, otherwise it is resolved again for fitted font size
 	ChildAlign             string
+	AlignSelf              string
 	IsChildrenDirectionRow bool
-	Justify                string
-	ChildrenColumnAlign    string
-	IsWrappingEnabled      bool
-	Padding                utils.TopRightBottomLeft
-	LineHeight             float64
-	BorderRadius           utils.FourValues
-	AbsolutePosition       utils.AbsolutePosition
-	InnerGap               float64
-	Rotation               float64
-	BkgImageSize           BkgImageSizeType
-	Border                 utils.Border
-	Offset                 utils.TopRightBottomLeft
+	// IsChildrenDirectionGrid means that children are placed in cells of GridColumns
+	IsChildrenDirectionGrid bool
+	Justify                 string
+	ChildrenColumnAlign     string
+	IsWrappingEnabled       bool
+	TextAlign               string // empty means rows of text are positioned with justify
+	TextTransform           string // applied to text before splitting
+	MaxLines                int    // 0 means no limit
+	Ellipsis                string
+	TextDecoration          utils.TextDecoration
+	TextStroke              utils.TextStroke
+	TextShadow              utils.TextShadow
+	GridColumns             []GridTrack
+	GridRowGap              float64
+	GridColumnGap           float64
+	ColumnSpan              int
+	RowSpan                 int
+	Padding                 utils.TopRightBottomLeft
+	Margin                  utils.TopRightBottomLeft
+	LineHeight              float64
+	BorderRadius            utils.FourValues
+	IsOverflowHidden        bool
+	AbsolutePosition        utils.AbsolutePosition
+	InnerGap                float64
+	Rotation                float64
+	BkgImageSize            BkgImageSizeType
+	Border                  utils.Border
+	Offset                  utils.TopRightBottomLeft
+	Grow                    float64
+	Shrink                  float64
 }
 
 // Node represents positioned and prepared element to render after layout phase
@@ -47,11 +73,33 @@ type Node struct {
 	TextHasHyphenAtEnd bool
 	Level              int
 	Face               font.Face
+	// Baseline is offset of the first text line baseline from the top of node,
+	// or height of node if it has no text
+	Baseline float64
+	// HasAutoWidth and HasAutoHeight are true if size of node is calculated from its content
+	HasAutoWidth  bool
+	HasAutoHeight bool
+	// TextDecorationWidth is width of text decoration, that continues over whitespace
+	// to the next node in row with the same decoration
+	TextDecorationWidth float64
 
 	RowIndex   int
 	InRowIndex int
 }
 
+// OuterSize returns size of node with its margins, that is space node takes in parent
+func (n *Node) OuterSize() utils.Size {
+	return utils.Size{
+		W: n.Size.W + n.Props.Margin.Left() + n.Props.Margin.Right(),
+		H: n.Size.H + n.Props.Margin.Top() + n.Props.Margin.Bottom(),
+	}
+}
+
+// OuterBaseline returns baseline of node with its top margin
+func (n *Node) OuterBaseline() float64 {
+	return n.Props.Margin.Top() + n.Baseline
+}
+
 func (n *Node) IsAbsolutePositioned() bool {
 	return n.Props.AbsolutePosition.Has()
 }
@@ -122,7 +170,7 @@ func (nodes Nodes) RowsTotalHeight(level int, from int, gap float64) (height flo
 			return
 		}
 		count += 1
-		height += node.Size.H
+		height += node.OuterSize().H
 	})
 	return height + float64(count-1)*gap, count
 }
@@ -137,7 +185,7 @@ func (nodes Nodes) RowTotalWidth(level int, from int, rowIndex int, textWhitespa
 			return
 		}
 
-		total += cn.Size.W
+		total += cn.OuterSize().W
 		if cn.TextHasHyphenAtEnd {
 			hyphensCount += 1
 		}
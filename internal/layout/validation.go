@@ -0,0 +1,180 @@
+package layout
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+var valueTokenRegex = regexp.MustCompile(`(?i)^-?\d+(\.\d+)?(%|w|h)?$`)
+var anchorTokenRegex = regexp.MustCompile(`^(top|right|bottom|left)(/-?\d+(\.\d+)?)?$`)
+
+// spanFields are fields that spans can have, all of them only affect text
+var spanFields = []string{
+	"id", "forEach", "if", "else", "text", "textTransform", "textDecoration", "textStroke", "textShadow",
+	"font", "fontFamily", "fontSize", "fontWeight", "fontStyle", "fontFeatures", "fontColor", "color",
+	"letterSpacing", "lineHeight",
+}
+
+// fieldValidators check not templated values of fields at compile time
+var fieldValidators = map[string]func(v string) error{
+	"size":             nValuesValidator(2),
+	"width":            nValuesValidator(1),
+	"height":           nValuesValidator(1),
+	"aspectRatio":      validateAspectRatio,
+	"minWidth":         nValuesValidator(1),
+	"maxWidth":         nValuesValidator(1),
+	"minHeight":        nValuesValidator(1),
+	"maxHeight":        nValuesValidator(1),
+	"lineHeight":       nValuesValidator(1),
+	"padding":          nValuesValidator(4),
+	"margin":           nValuesValidator(4),
+	"borderRadius":     nValuesValidator(4),
+	"innerGap":         nValuesValidator(1),
+	"rotate":           nValuesValidator(1),
+	"fontSize":         validateFontSize,
+	"minFontSize":      nValuesValidator(1),
+	"maxFontSize":      nValuesValidator(1),
+	"fontWeight":       nValuesValidator(1),
+	"bkgColor":         validateColor,
+	"fontColor":        validateColor,
+	"color":            validateColor,
+	"border":           validateBorder,
+	"absolute":         validateAnchors,
+	"offset":           validateAnchors,
+	"innerDirection":   enumValidator(innerDirectionValues),
+	"justify":          enumValidator(justifyValues),
+	"innerColumnAlign": enumValidator(innerColumnAlignValues),
+	"align":            enumValidator(alignValues),
+	"alignSelf":        enumValidator(alignSelfValues),
+	"innerWrap":        enumValidator(innerWrapValues),
+	"textAlign":        enumValidator(textAlignValues),
+	"textTransform":    enumValidator(textTransformValues),
+	"letterSpacing":    validateLetterSpacing,
+	"fontFeatures":     validateFontFeatures,
+	"textDecoration":   validateTextDecoration,
+	"textStroke":       validateTextStroke,
+	"textShadow":       validateTextShadow,
+	"maxLines":         validateMaxLines,
+	"gridColumns":      validateGridColumns,
+	"gridGap":          nValuesValidator(2),
+	"columnSpan":       validateSpan,
+	"rowSpan":          validateSpan,
+	"bkgImageSize":     enumValidator(bkgImageSizeValues),
+	"overflow":         enumValidator(overflowValues),
+	"fontStyle":        enumValidator(fontStyleValues),
+	"else":             enumValidator([]string{"true", "false"}),
+	"grow":             validateFactor,
+	"shrink":           validateFactor,
+	"scale":            validateScale,
+}
+
+func nValuesValidator(max int) func(v string) error {
+	return func(v string) error {
+		tokens := strings.Fields(v)
+		if len(tokens) > max {
+			return fmt.Errorf("expected at most %v values, got %v", max, len(tokens))
+		}
+		for _, t := range tokens {
+			if !valueTokenRegex.MatchString(t) {
+				return fmt.Errorf("malformed value \"%v\", expected number with optional %%, w or h unit", t)
+			}
+		}
+		return nil
+	}
+}
+
+func enumValidator(options []string) func(v string) error {
+	return func(v string) error {
+		if !slices.Contains(options, v) {
+			return fmt.Errorf("invalid value \"%v\", expected one of %v", v, strings.Join(options, ", "))
+		}
+		return nil
+	}
+}
+
+func validateColor(v string) error {
+	_, err := parseColor(v)
+	return err
+}
+
+func validateBorder(v string) error {
+	_, err := parseBorderProperty(v)
+	return err
+}
+
+func validateTextDecoration(v string) error {
+	_, err := parseTextDecoration(v)
+	return err
+}
+
+func validateTextStroke(v string) error {
+	_, err := parseTextStroke(v)
+	return err
+}
+
+func validateTextShadow(v string) error {
+	_, err := parseTextShadow(v)
+	return err
+}
+
+func validateAnchors(v string) error {
+	for _, t := range strings.Fields(v) {
+		if !anchorTokenRegex.MatchString(t) {
+			return fmt.Errorf("malformed anchor \"%v\", expected top, right, bottom or left with optional /offset", t)
+		}
+	}
+	return nil
+}
+
+func validateScale(v string) error {
+	if _, err := strconv.ParseFloat(v, 64); err != nil {
+		return fmt.Errorf("malformed scale \"%v\"", v)
+	}
+	return nil
+}
+
+func validateFactor(v string) error {
+	_, err := parseFactor(v)
+	return err
+}
+
+func validateAspectRatio(v string) error {
+	_, err := parseAspectRatio(v)
+	return err
+}
+
+func validateGridColumns(v string) error {
+	_, err := parseGridTracks(v)
+	return err
+}
+
+func validateSpan(v string) error {
+	_, err := parseSpan(v)
+	return err
+}
+
+func validateFontSize(v string) error {
+	if v == "fit" {
+		return nil
+	}
+	return nValuesValidator(1)(v)
+}
+
+func validateLetterSpacing(v string) error {
+	_, err := parseLetterSpacing(v)
+	return err
+}
+
+func validateFontFeatures(v string) error {
+	_, err := parseFontFeatures(v)
+	return err
+}
+
+func validateMaxLines(v string) error {
+	_, err := parseMaxLines(v)
+	return err
+}
@@ -0,0 +1,267 @@
+package parsing
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/antonmedv/expr/ast"
+	"github.com/antonmedv/expr/parser"
+	"golang.org/x/exp/slices"
+)
+
+// ExpandComponents replaces every node with `use` by the body of referenced component,
+// so the rest of the pipeline works with a plain tree.
+// Constant params are bound to expressions inside component body with `let` declarations,
+// so they are visible to expressions as regular variables. Templated params are kept
+// in BoundParams of body root, see Node.BoundParams.
+func ExpandComponents(root Node) (Node, error) {
+	components := root.Components
+	root.Components = nil
+	return expandNode(root, components, nil)
+}
+
+func expandNode(n Node, components map[string]Component, usedComponents []string) (Node, error) {
+	if n.Use != "" {
+		c, has := components[n.Use]
+		if !has {
+			return n, useError(n, fmt.Errorf("component %v not found", n.Use))
+		}
+		if slices.Contains(usedComponents, n.Use) {
+			return n, useError(n, fmt.Errorf("component %v recursively uses itself (%v)", n.Use, strings.Join(append(usedComponents, n.Use), " -> ")))
+		}
+		if len(n.Inner) > 0 || len(n.Spans) > 0 {
+			return n, useError(n, fmt.Errorf("node that uses component %v can't have inner nodes or spans", n.Use))
+		}
+
+		params := make(map[string]string, len(c.Params))
+		for name, defaultValue := range c.Params {
+			params[name] = defaultValue
+		}
+		for name, value := range n.Props {
+			if _, has := c.Params[name]; !has {
+				return n, useError(n, fmt.Errorf("component %v has no param %v", n.Use, name))
+			}
+			params[name] = value
+		}
+
+		// Constant params are inlined into body, templated ones are evaluated at layout
+		// in scope of this node, so they are bound to root of body
+		constParams := make(map[string]string, len(params))
+		var boundParams map[string]string
+		for name, value := range params {
+			if !strings.HasPrefix(value, "~") {
+				constParams[name] = value
+				continue
+			}
+			// Node may be root of another component body itself, its params are evaluated in the same scope
+			var err error
+			if value, err = bindParamsToExpression(value, n.BoundParams); err != nil {
+				return n, useError(n, fmt.Errorf("component %v: param %v: %w", n.Use, name, err))
+			}
+			if boundParams == nil {
+				boundParams = make(map[string]string, len(params)+len(n.BoundParams))
+			}
+			boundParams[name] = value
+		}
+
+		body, err := bindParams(c.Node, constParams)
+		if err != nil {
+			return n, useError(n, fmt.Errorf("component %v: %w", n.Use, err))
+		}
+
+		// Fields specified on node itself take precedence over component root fields
+		overrideStringFields(&body, n)
+
+		for name, value := range n.BoundParams {
+			if _, has := params[name]; !has {
+				if boundParams == nil {
+					boundParams = make(map[string]string, len(n.BoundParams))
+				}
+				boundParams[name] = value
+			}
+		}
+		body.BoundParams = boundParams
+		if pos, has := n.FieldPositions["props"]; has && boundParams != nil {
+			body.FieldPositions["props"] = pos
+		}
+
+		usedComponents = append(usedComponents[0:len(usedComponents):len(usedComponents)], n.Use)
+		return expandNode(body, components, usedComponents)
+	}
+
+	return mapChildNodes(n, func(cn Node) (Node, error) {
+		return expandNode(cn, components, usedComponents)
+	})
+}
+
+// useError is error of node that uses component, it points to use field
+func useError(n Node, err error) error {
+	return &ValidationError{Position: n.FieldPositions["use"], Field: "use", Err: err}
+}
+
+// bindParams returns deep copy of node with constant params bound to all templated fields
+func bindParams(n Node, params map[string]string) (Node, error) {
+	var err error
+
+	IterateStringFields(&n, func(name string, field *string) bool {
+		*field, err = bindParamsToExpression(*field, params)
+		if err != nil {
+			err = fmt.Errorf("field %v: %w", name, err)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return n, err
+	}
+
+	if len(n.Props) > 0 {
+		props := make(map[string]string, len(n.Props))
+		for name, value := range n.Props {
+			if props[name], err = bindParamsToExpression(value, params); err != nil {
+				return n, fmt.Errorf("prop %v: %w", name, err)
+			}
+		}
+		n.Props = props
+	}
+
+	if len(n.Inner) > 0 {
+		inner := make([]Node, len(n.Inner))
+		for i, cn := range n.Inner {
+			if inner[i], err = bindParams(cn, params); err != nil {
+				return n, err
+			}
+		}
+		n.Inner = inner
+	}
+
+	if len(n.Spans) > 0 {
+		spans := make([]Node, len(n.Spans))
+		for i, sn := range n.Spans {
+			if spans[i], err = bindParams(sn, params); err != nil {
+				return n, err
+			}
+		}
+		n.Spans = spans
+	}
+
+	return n, nil
+}
+
+type identifiersCollector struct {
+	names []string
+}
+
+func (ic *identifiersCollector) Visit(node *ast.Node) {
+	if n, ok := (*node).(*ast.IdentifierNode); ok {
+		ic.names = append(ic.names, n.Value)
+	}
+}
+
+// bindParamsToExpression declares used params at the beginning of expression, e.g.
+// with param `name: ~ value.Name` expression `~ name + '!'` becomes `~ let name = (value.Name); name + '!'`.
+// Param values without `~` are constant strings, numbers and booleans are turned into `~` literals when template is read.
+func bindParamsToExpression(str string, params map[string]string) (string, error) {
+	if !strings.HasPrefix(str, "~") {
+		return str, nil
+	}
+
+	src := strings.TrimSpace(strings.TrimLeft(str, "~"))
+
+	tree, err := parser.Parse(src)
+	if err != nil {
+		return str, err
+	}
+
+	// Shortcut for the most common case, when whole expression is just a param with constant value
+	if ident, ok := tree.Node.(*ast.IdentifierNode); ok {
+		if value, has := params[ident.Value]; has && !strings.HasPrefix(value, "~") {
+			return value, nil
+		}
+	}
+
+	collector := identifiersCollector{}
+	ast.Walk(&tree.Node, &collector)
+
+	var declarations []string
+	for name, value := range params {
+		if !slices.Contains(collector.names, name) {
+			continue
+		}
+		if strings.HasPrefix(value, "~") {
+			value = "(" + strings.TrimSpace(strings.TrimLeft(value, "~")) + ")"
+		} else {
+			value = strconv.Quote(value)
+		}
+		declarations = append(declarations, fmt.Sprintf("let %v = %v; ", name, value))
+	}
+
+	if len(declarations) == 0 {
+		return str, nil
+	}
+
+	// Map iteration order is random, keep result stable
+	sort.Strings(declarations)
+
+	return "~ " + strings.Join(declarations, "") + src, nil
+}
+
+var nodeType = reflect.TypeOf(Node{})
+
+// mapChildNodes returns copy of node with inner nodes and spans replaced by result of fn
+func mapChildNodes(n Node, fn func(cn Node) (Node, error)) (Node, error) {
+	for _, children := range []*[]Node{&n.Inner, &n.Spans} {
+		if len(*children) == 0 {
+			continue
+		}
+		mapped := make([]Node, len(*children))
+		for i, cn := range *children {
+			var err error
+			if mapped[i], err = fn(cn); err != nil {
+				return n, err
+			}
+		}
+		*children = mapped
+	}
+	return n, nil
+}
+
+// IterateStringFields calls cb for every string property of node (not including children)
+// with its yaml name. Iteration stops when cb returns false.
+func IterateStringFields(n *Node, cb func(name string, field *string) bool) {
+	v := reflect.ValueOf(n).Elem()
+	for i := 0; i < nodeType.NumField(); i++ {
+		f := nodeType.Field(i)
+		if f.Type.Kind() != reflect.String {
+			continue
+		}
+		if !cb(strings.Split(f.Tag.Get("yaml"), ",")[0], v.Field(i).Addr().Interface().(*string)) {
+			return
+		}
+	}
+}
+
+func overrideStringFields(dst *Node, src Node) {
+	positions := make(map[string]Position, len(dst.FieldPositions))
+	for name, pos := range dst.FieldPositions {
+		positions[name] = pos
+	}
+
+	dv, sv := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src)
+	for i := 0; i < nodeType.NumField(); i++ {
+		f := nodeType.Field(i)
+		if f.Type.Kind() != reflect.String || f.Name == "Use" || sv.Field(i).String() == "" {
+			continue
+		}
+		dv.Field(i).SetString(sv.Field(i).String())
+
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if pos, has := src.FieldPositions[name]; has {
+			positions[name] = pos
+		}
+	}
+
+	dst.FieldPositions = positions
+}
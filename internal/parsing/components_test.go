@@ -0,0 +1,137 @@
+package parsing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindParamsToExpression(t *testing.T) {
+	params := map[string]string{
+		"title": "Hello",
+		"name":  "~ value.Name",
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Not templated",
+			input:    "title",
+			expected: "title",
+		},
+		{
+			name:     "Constant param only",
+			input:    "~ title",
+			expected: "Hello",
+		},
+		{
+			name:     "Expression param only",
+			input:    "~ name",
+			expected: "~ let name = (value.Name); name",
+		},
+		{
+			name:     "Several params",
+			input:    "~ title + name",
+			expected: "~ let name = (value.Name); let title = \"Hello\"; title + name",
+		},
+		{
+			name:     "No params used",
+			input:    "~ value.title",
+			expected: "~ value.title",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := bindParamsToExpression(tt.input, params)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestExpandComponents(t *testing.T) {
+	root := Node{
+		Components: map[string]Component{
+			"card": {
+				Params: map[string]string{"title": ""},
+				Node: Node{
+					BkgColor: "red",
+					Inner:    []Node{{Text: "~ title"}},
+				},
+			},
+			"loop": {
+				Node: Node{Use: "loop"},
+			},
+		},
+		Inner: []Node{
+			{Use: "card", BkgColor: "blue", Props: map[string]string{"title": "First"}},
+			{Use: "card"},
+		},
+	}
+
+	expanded, err := ExpandComponents(root)
+	assert.NoError(t, err)
+	assert.Nil(t, expanded.Components)
+	assert.Equal(t, "blue", expanded.Inner[0].BkgColor)
+	assert.Equal(t, "First", expanded.Inner[0].Inner[0].Text)
+	assert.Equal(t, "red", expanded.Inner[1].BkgColor)
+	assert.Equal(t, "", expanded.Inner[1].Inner[0].Text)
+
+	// Components can be used in spans as well
+	root.Components["price"] = Component{Params: map[string]string{"amount": ""}, Node: Node{Text: "~ amount", Color: "red"}}
+	root.Inner = []Node{{Spans: []Node{{Text: "Total: "}, {Use: "price", Props: map[string]string{"amount": "$42"}}}}}
+	expanded, err = ExpandComponents(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "$42", expanded.Inner[0].Spans[1].Text)
+	assert.Equal(t, "red", expanded.Inner[0].Spans[1].Color)
+
+	root.Inner = []Node{{Use: "card", Props: map[string]string{"unknown": "1"}}}
+	_, err = ExpandComponents(root)
+	assert.ErrorContains(t, err, "use: component card has no param unknown")
+
+	root.Inner = []Node{{Use: "loop"}}
+	_, err = ExpandComponents(root)
+	assert.ErrorContains(t, err, "use: component loop recursively uses itself (loop -> loop)")
+}
+
+func TestExpandComponentsTemplatedParams(t *testing.T) {
+	root := Node{
+		Components: map[string]Component{
+			"card": {
+				Params: map[string]string{"label": "", "suffix": "!"},
+				Node: Node{
+					Inner: []Node{{ForEach: "2", Text: "~ label + suffix"}},
+				},
+			},
+			"wrapper": {
+				Params: map[string]string{"name": ""},
+				Node: Node{
+					Use:   "card",
+					Props: map[string]string{"label": "~ upper(name)"},
+				},
+			},
+		},
+		Inner: []Node{
+			{Use: "card", ForEach: "Names", Props: map[string]string{"label": "~ value"}},
+			{Use: "wrapper", Props: map[string]string{"name": "~ value.Name"}},
+		},
+	}
+
+	expanded, err := ExpandComponents(root)
+	assert.NoError(t, err)
+
+	// Templated param is evaluated at root of body in scope of node with use, not in scope of body nodes,
+	// e.g. in forEach iterations of component
+	card := expanded.Inner[0]
+	assert.Equal(t, "Names", card.ForEach)
+	assert.Equal(t, map[string]string{"label": "~ value"}, card.BoundParams)
+	assert.Equal(t, "~ let suffix = \"!\"; label + suffix", card.Inner[0].Text)
+
+	// Params of nested component that use params of outer one are evaluated in the same scope
+	wrapper := expanded.Inner[1]
+	assert.Equal(t, map[string]string{"name": "~ value.Name", "label": "~ let name = (value.Name); upper(name)"}, wrapper.BoundParams)
+}
@@ -1,6 +1,8 @@
 package parsing
 
-func KeepDebugNodes(n Node) Node {
+// KeepDebugNodes returns tree with only first node marked with "only" field under root.
+// Result is false if there are no such nodes.
+func KeepDebugNodes(n Node) (Node, bool) {
 	var debugNode *Node
 	iterateNode(n, func(n Node) bool {
 		if n.DebugOnly != "" {
@@ -9,10 +11,11 @@ func KeepDebugNodes(n Node) Node {
 		}
 		return true
 	})
-	if debugNode != nil {
-		n.Inner = []Node{*debugNode}
+	if debugNode == nil {
+		return n, false
 	}
-	return n
+	n.Inner = []Node{*debugNode}
+	return n, true
 }
 
 func iterateNode(n Node, cb func(n Node) bool) {
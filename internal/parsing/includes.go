@@ -0,0 +1,145 @@
+package parsing
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+// rootTemplateName is used in errors when template is not loaded from file
+const rootTemplateName = "template"
+
+type includesResolver struct {
+	files fs.FS
+	// includedFiles are all files that were included, in order of first inclusion
+	includedFiles []string
+	components    map[string]Component
+	// componentsFiles are names of files where components are declared
+	componentsFiles map[string]string
+	fontFaces       []FontFace
+	validationErrs  ValidationErrors
+}
+
+// Load decodes template and recursively resolves all includes with files.
+// It returns root node with components from all included files and list of included files.
+// Unknown fields are reported as ValidationErrors, in that case root node is still returned.
+func Load(fileName string, content []byte, files fs.FS) (Node, []string, error) {
+	if fileName == "" {
+		fileName = rootTemplateName
+	}
+	fileName = path.Clean(fileName)
+
+	r := includesResolver{
+		files:           files,
+		components:      make(map[string]Component),
+		componentsFiles: make(map[string]string),
+	}
+
+	root, err := r.resolveFile(fileName, content, nil)
+	if err != nil {
+		// Files read before error are returned too, e.g. to watch the broken one
+		return root, r.includedFiles, err
+	}
+
+	root.Components = r.components
+	root.FontFaces = append(root.FontFaces, r.fontFaces...)
+
+	if len(r.validationErrs) > 0 {
+		return root, r.includedFiles, r.validationErrs
+	}
+
+	return root, r.includedFiles, nil
+}
+
+func (r *includesResolver) resolveFile(fileName string, content []byte, includeStack []string) (Node, error) {
+	var n Node
+
+	node := yaml.Node{}
+	if err := yaml.Unmarshal(content, &node); err != nil {
+		return n, fmt.Errorf("%v: %w", fileName, err)
+	}
+
+	if err := node.Decode(&n); err != nil {
+		return n, fmt.Errorf("%v: %w", fileName, err)
+	}
+
+	if len(node.Content) > 0 {
+		readPositions(&n, node.Content[0], fileName, nodeKeys, &r.validationErrs)
+	}
+
+	// Font faces and components of included files are shared with whole template
+	if len(includeStack) > 0 {
+		r.fontFaces = append(r.fontFaces, n.FontFaces...)
+		n.FontFaces = nil
+	}
+
+	includeStack = append(includeStack[0:len(includeStack):len(includeStack)], fileName)
+
+	for name, c := range n.Components {
+		if declaredIn, has := r.componentsFiles[name]; has {
+			if declaredIn == fileName {
+				// same file included more than once
+				continue
+			}
+			return n, fmt.Errorf("%v: component %v is already declared in %v", fileName, name, declaredIn)
+		}
+		var err error
+		if c.Node, err = r.resolveNode(c.Node, includeStack); err != nil {
+			return n, err
+		}
+		r.components[name] = c
+		r.componentsFiles[name] = fileName
+	}
+	n.Components = nil
+
+	return r.resolveNode(n, includeStack)
+}
+
+func (r *includesResolver) resolveNode(n Node, includeStack []string) (Node, error) {
+	fileName := includeStack[len(includeStack)-1]
+
+	if n.Include != "" {
+		// Path is relative to directory of including file. Template read by absolute path
+		// is not in files, so its includes are relative to root of files.
+		dir := path.Dir(fileName)
+		if path.IsAbs(dir) {
+			dir = "."
+		}
+		includedFileName := path.Join(dir, n.Include)
+
+		if slices.Contains(includeStack, includedFileName) {
+			return n, fmt.Errorf("%v: include cycle %v", fileName, strings.Join(append(includeStack, includedFileName), " -> "))
+		}
+		if len(n.Inner) > 0 || len(n.Spans) > 0 {
+			return n, fmt.Errorf("%v: node that includes %v can't have inner nodes or spans", fileName, n.Include)
+		}
+
+		content, err := fs.ReadFile(r.files, includedFileName)
+		if err != nil {
+			return n, fmt.Errorf("%v: can't include %v: %w", fileName, n.Include, err)
+		}
+
+		if !slices.Contains(r.includedFiles, includedFileName) {
+			r.includedFiles = append(r.includedFiles, includedFileName)
+		}
+
+		included, err := r.resolveFile(includedFileName, content, includeStack)
+		if err != nil {
+			return n, err
+		}
+
+		// Fields specified on node itself take precedence over included root fields
+		overrideStringFields(&included, n)
+		included.Include = ""
+
+		return included, nil
+	}
+
+	return mapChildNodes(n, func(cn Node) (Node, error) {
+		return r.resolveNode(cn, includeStack)
+	})
+}
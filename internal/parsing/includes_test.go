@@ -0,0 +1,45 @@
+package parsing
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	files := fstest.MapFS{
+		"partials/footer.yaml":    {Data: []byte("bkgColor: red\ninner:\n  - include: ./text.yaml\n")},
+		"partials/text.yaml":      {Data: []byte("components:\n  label:\n    text: label\ntext: Footer\n")},
+		"partials/price.yaml":     {Data: []byte("text: $42\ncolor: red\n")},
+		"partials/cycle.yaml":     {Data: []byte("inner:\n  - include: cycle2.yaml\n")},
+		"partials/cycle2.yaml":    {Data: []byte("inner:\n  - include: ../partials/cycle.yaml\n")},
+		"sub/card.yaml":           {Data: []byte("inner:\n  - include: ./partials/title.yaml\n")},
+		"sub/partials/title.yaml": {Data: []byte("text: Title\n")},
+	}
+
+	root, includedFiles, err := Load("layout.yaml", []byte("inner:\n  - include: partials/footer.yaml\n    bkgColor: blue\n"), files)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"partials/footer.yaml", "partials/text.yaml"}, includedFiles)
+	assert.Equal(t, "blue", root.Inner[0].BkgColor)
+	assert.Equal(t, "", root.Inner[0].Include)
+	assert.Equal(t, "Footer", root.Inner[0].Inner[0].Text)
+	assert.Contains(t, root.Components, "label")
+
+	root, _, err = Load("layout.yaml", []byte("spans:\n  - text: \"Total: \"\n  - include: partials/price.yaml\n"), files)
+	assert.NoError(t, err)
+	assert.Equal(t, "$42", root.Spans[1].Text)
+	assert.Equal(t, "red", root.Spans[1].Color)
+
+	_, _, err = Load("layout.yaml", []byte("include: partials/cycle.yaml"), files)
+	assert.EqualError(t, err, "partials/cycle2.yaml: include cycle layout.yaml -> partials/cycle.yaml -> partials/cycle2.yaml -> partials/cycle.yaml")
+
+	// Included paths are relative to directory of including file
+	root, includedFiles, err = Load("sub/layout.yaml", []byte("include: card.yaml"), files)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sub/card.yaml", "sub/partials/title.yaml"}, includedFiles)
+	assert.Equal(t, "Title", root.Inner[0].Text)
+
+	_, _, err = Load("", []byte("inner:\n  - include: partials/missing.yaml"), files)
+	assert.ErrorContains(t, err, "template: can't include partials/missing.yaml")
+}
@@ -9,6 +9,11 @@ type Node struct {
 	Size                string     `yaml:"size"`
 	Width               string     `yaml:"width"`
 	Height              string     `yaml:"height"`
+	AspectRatio         string     `yaml:"aspectRatio"`
+	MinWidth            string     `yaml:"minWidth"`
+	MaxWidth            string     `yaml:"maxWidth"`
+	MinHeight           string     `yaml:"minHeight"`
+	MaxHeight           string     `yaml:"maxHeight"`
 	Absolute            string     `yaml:"absolute"`
 	Offset              string     `yaml:"offset"`
 	BkgColor            string     `yaml:"bkgColor"`
@@ -16,29 +21,68 @@ type Node struct {
 	InnerDirection      string     `yaml:"innerDirection"`
 	Justify             string     `yaml:"justify"`
 	ChildrenColumnAlign string     `yaml:"innerColumnAlign"`
+	Align               string     `yaml:"align"`
+	AlignSelf           string     `yaml:"alignSelf"`
 	ChildrenWrap        string     `yaml:"innerWrap"`
+	GridColumns         string     `yaml:"gridColumns"`
+	GridGap             string     `yaml:"gridGap"`
+	ColumnSpan          string     `yaml:"columnSpan"`
+	RowSpan             string     `yaml:"rowSpan"`
 	Padding             string     `yaml:"padding"`
+	Margin              string     `yaml:"margin"`
 	Text                string     `yaml:"text"`
+	TextAlign           string     `yaml:"textAlign"`
+	TextTransform       string     `yaml:"textTransform"`
+	TextDecoration      string     `yaml:"textDecoration"`
+	TextStroke          string     `yaml:"textStroke"`
+	TextShadow          string     `yaml:"textShadow"`
+	MaxLines            string     `yaml:"maxLines"`
+	Ellipsis            string     `yaml:"ellipsis"`
 	Image               string     `yaml:"bkgImage"`
 	FontFaces           []FontFace `yaml:"fontFaces"`
 	Font                string     `yaml:"font"`
 	FontFamily          string     `yaml:"fontFamily"`
 	FontSize            string     `yaml:"fontSize"`
+	MinFontSize         string     `yaml:"minFontSize"`
+	MaxFontSize         string     `yaml:"maxFontSize"`
+	LetterSpacing       string     `yaml:"letterSpacing"`
 	FontWeight          string     `yaml:"fontWeight"`
 	FontStyle           string     `yaml:"fontStyle"`
+	FontFeatures        string     `yaml:"fontFeatures"`
 	FontColor           string     `yaml:"fontColor"`
 	Color               string     `yaml:"color"` // same as fontColor
 	BorderRadius        string     `yaml:"borderRadius"`
+	Overflow            string     `yaml:"overflow"`
 	InnerGap            string     `yaml:"innerGap"`
 	Rotation            string     `yaml:"rotate"`
 	DebugOnly           string     `yaml:"only"`
 	BkgImageSize        string     `yaml:"bkgImageSize"`
 	Border              string     `yaml:"border"`
 	Scale               string     `yaml:"scale"`
+	Grow                string     `yaml:"grow"`
+	Shrink              string     `yaml:"shrink"`
 	Sample              any        `yaml:"sample"`
 
 	ForEach string `yaml:"forEach"`
+	If      string `yaml:"if"`
+	Else    string `yaml:"else"`
 	Inner   []Node `yaml:"inner"`
+	Spans   []Node `yaml:"spans"`
+
+	Components map[string]Component `yaml:"components"`
+	Use        string               `yaml:"use"`
+	Props      map[string]string    `yaml:"props"`
+	// BoundParams are templated params of expanded component, set on root of component body.
+	// They are evaluated once in scope of node that uses component and are visible to the whole body.
+	BoundParams map[string]string `yaml:"-"`
+	Include     string            `yaml:"include"`
+
+	// Translations are files with translations catalogs by language, e.g. en: i18n/en.yaml
+	Translations map[string]string `yaml:"translations"`
+
+	// Position and FieldPositions point to template source of node and its fields
+	Position       Position            `yaml:"-"`
+	FieldPositions map[string]Position `yaml:"-"`
 }
 
 func (n *Node) GetScale() float64 {
@@ -49,6 +93,13 @@ func (n *Node) GetScale() float64 {
 	return scale
 }
 
+// Component is a reusable subtree declared in top-level components section.
+// Params are names of parameters with their default values.
+type Component struct {
+	Params map[string]string `yaml:"params"`
+	Node   `yaml:",inline"`
+}
+
 type FontFace struct {
 	Family string `yaml:"family"`
 	Style  string `yaml:"style"`
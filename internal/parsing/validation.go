@@ -0,0 +1,230 @@
+package parsing
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+// Position is a location in template source
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%v:%v:%v", p.File, p.Line, p.Column)
+}
+
+// ValidationError describes problem with particular field of template
+type ValidationError struct {
+	Position
+	Field string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %v: %v", e.Position, e.Field, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors are all problems found in template
+type ValidationErrors []*ValidationError
+
+func (ve ValidationErrors) Error() string {
+	lines := make([]string, len(ve))
+	for i, e := range ve {
+		lines[i] = e.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (ve ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(ve))
+	for i, e := range ve {
+		errs[i] = e
+	}
+	return errs
+}
+
+// Normalize sorts errors by position and removes duplicates,
+// that appear when the same source is used several times (e.g. component).
+func (ve ValidationErrors) Normalize() ValidationErrors {
+	slices.SortFunc(ve, func(a, b *ValidationError) bool {
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Error() < b.Error()
+	})
+	return slices.CompactFunc(ve, func(a, b *ValidationError) bool {
+		return a.Error() == b.Error()
+	})
+}
+
+var nodeKeys = getYamlKeys(reflect.TypeOf(Node{}))
+var componentKeys = getYamlKeys(reflect.TypeOf(Component{}))
+var fontFaceKeys = getYamlKeys(reflect.TypeOf(FontFace{}))
+
+func getYamlKeys(t reflect.Type) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		tagParts := strings.Split(t.Field(i).Tag.Get("yaml"), ",")
+		if slices.Contains(tagParts[1:], "inline") {
+			keys = append(keys, getYamlKeys(t.Field(i).Type)...)
+		} else if tagParts[0] != "" && tagParts[0] != "-" {
+			keys = append(keys, tagParts[0])
+		}
+	}
+	return keys
+}
+
+// readPositions stores positions of yaml fields to decoded node and reports unknown keys
+func readPositions(n *Node, yn *yaml.Node, fileName string, knownKeys []string, errs *ValidationErrors) {
+	if yn.Kind != yaml.MappingNode {
+		return
+	}
+
+	checkKnownKeys(yn, fileName, knownKeys, errs)
+
+	n.Position = Position{File: fileName, Line: yn.Line, Column: yn.Column}
+	n.FieldPositions = make(map[string]Position, len(yn.Content)/2)
+
+	for i := 0; i+1 < len(yn.Content); i += 2 {
+		key, value := yn.Content[i], yn.Content[i+1]
+
+		n.FieldPositions[key.Value] = Position{File: fileName, Line: value.Line, Column: value.Column}
+
+		switch key.Value {
+		case "props":
+			readLiteralParams(n.Props, value)
+		case "inner":
+			if value.Kind == yaml.SequenceNode && len(value.Content) == len(n.Inner) {
+				for j := range n.Inner {
+					readPositions(&n.Inner[j], value.Content[j], fileName, nodeKeys, errs)
+					checkNestedComponents(&n.Inner[j], errs)
+				}
+			}
+		case "spans":
+			if value.Kind == yaml.SequenceNode && len(value.Content) == len(n.Spans) {
+				for j := range n.Spans {
+					readPositions(&n.Spans[j], value.Content[j], fileName, nodeKeys, errs)
+					checkNestedComponents(&n.Spans[j], errs)
+				}
+			}
+		case "components":
+			if value.Kind == yaml.MappingNode {
+				for j := 0; j+1 < len(value.Content); j += 2 {
+					name := value.Content[j].Value
+					if c, has := n.Components[name]; has {
+						readPositions(&c.Node, value.Content[j+1], fileName, componentKeys, errs)
+						checkNestedComponents(&c.Node, errs)
+						if params := mappingValue(value.Content[j+1], "params"); params != nil {
+							readLiteralParams(c.Params, params)
+						}
+						n.Components[name] = c
+					}
+				}
+			}
+		case "fontFaces":
+			if value.Kind == yaml.SequenceNode {
+				for _, fn := range value.Content {
+					checkKnownKeys(fn, fileName, fontFaceKeys, errs)
+				}
+			}
+		}
+	}
+}
+
+// checkNestedComponents reports components declared below root of file, they are not collected and would be ignored
+func checkNestedComponents(n *Node, errs *ValidationErrors) {
+	if pos, has := n.FieldPositions["components"]; has {
+		*errs = append(*errs, &ValidationError{
+			Position: pos,
+			Field:    "components",
+			Err:      fmt.Errorf("components can be declared only at root of file"),
+		})
+	}
+}
+
+// readLiteralParams turns numbers and booleans of params into expressions, so they keep their types
+// in expressions of component instead of being passed as strings
+func readLiteralParams(params map[string]string, yn *yaml.Node) {
+	if yn.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(yn.Content); i += 2 {
+		name, value := yn.Content[i].Value, yn.Content[i+1]
+		if _, has := params[name]; !has || value.Kind != yaml.ScalarNode {
+			continue
+		}
+
+		// Literals are formatted again, because yaml allows forms that expressions don't, e.g. True or 1_000
+		var literal string
+		switch value.ShortTag() {
+		case "!!int":
+			var i int64
+			if value.Decode(&i) == nil {
+				literal = strconv.FormatInt(i, 10)
+			}
+		case "!!float":
+			var f float64
+			if value.Decode(&f) == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+				literal = strconv.FormatFloat(f, 'f', -1, 64)
+				if !strings.Contains(literal, ".") {
+					literal += ".0"
+				}
+			}
+		case "!!bool":
+			var b bool
+			if value.Decode(&b) == nil {
+				literal = strconv.FormatBool(b)
+			}
+		}
+		if literal != "" {
+			params[name] = "~ " + literal
+		}
+	}
+}
+
+// mappingValue returns value of key in yaml mapping, or nil if there is no such key
+func mappingValue(yn *yaml.Node, key string) *yaml.Node {
+	if yn.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(yn.Content); i += 2 {
+		if yn.Content[i].Value == key {
+			return yn.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func checkKnownKeys(yn *yaml.Node, fileName string, knownKeys []string, errs *ValidationErrors) {
+	if yn.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(yn.Content); i += 2 {
+		if key := yn.Content[i]; !slices.Contains(knownKeys, key.Value) {
+			*errs = append(*errs, &ValidationError{
+				Position: Position{File: fileName, Line: key.Line, Column: key.Column},
+				Field:    key.Value,
+				Err:      fmt.Errorf("unknown field"),
+			})
+		}
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/godknowsiamgood/decorender/internal/layout"
 	"github.com/godknowsiamgood/decorender/internal/utils"
 	"golang.org/x/image/draw"
-	"golang.org/x/image/math/fixed"
 	"image"
 	"image/color"
 	"math"
@@ -32,6 +31,10 @@ type drawState struct {
 	node *layout.Node
 	// pos is a current world position
 	pos utils.Pos
+	// clipDst is destination of node itself if its children are drawn to separate dst to be clipped,
+	// and clipPos is position of node in clipDst
+	clipDst *image.RGBA
+	clipPos utils.Pos
 }
 
 var stacksPool = sync.Pool{
@@ -54,6 +57,9 @@ func Do(nodes layout.Nodes, cache *Cache) (*image.RGBA, error) {
 			if topDst != stack[i].dst {
 				utils.ReleaseImage(stack[i].dst)
 			}
+			if stack[i].clipDst != nil && topDst != stack[i].clipDst {
+				utils.ReleaseImage(stack[i].clipDst)
+			}
 		}
 
 		stack = stack[0:0]
@@ -70,9 +76,12 @@ func Do(nodes layout.Nodes, cache *Cache) (*image.RGBA, error) {
 		n := &nodes[i]
 
 		// Ascend the stack if necessary
-		popupStack(&stack, n.Level)
+		popupStack(&stack, n.Level, dc.cache)
 
 		state := stack.Last() // next node, new state
+		state.clipDst = nil
+
+		var nodePos utils.Pos
 
 		// Create new destination image in case of root node and nodes that rotating
 		if state.dst == nil || math.Abs(n.Props.Rotation) > math.SmallestNonzeroFloat64 {
@@ -85,6 +94,7 @@ func Do(nodes layout.Nodes, cache *Cache) (*image.RGBA, error) {
 				utils.ReleaseImage(state.dst)
 				return nil, err
 			}
+			nodePos = utils.Pos{Left: borderOffset, Top: borderOffset}
 
 			// Next world position is just current node padding
 			state.pos = utils.Pos{
@@ -95,6 +105,7 @@ func Do(nodes layout.Nodes, cache *Cache) (*image.RGBA, error) {
 			if err := drawNode(state.dst, n, state.pos.Left+n.Pos.Left, state.pos.Top+n.Pos.Top, dc); err != nil {
 				return nil, err
 			}
+			nodePos = utils.Pos{Left: state.pos.Left + n.Pos.Left, Top: state.pos.Top + n.Pos.Top}
 
 			// Next world position is previous world + current node local position + current node padding
 			state.pos = utils.Pos{
@@ -103,19 +114,58 @@ func Do(nodes layout.Nodes, cache *Cache) (*image.RGBA, error) {
 			}
 		}
 
+		// Children of node with hidden overflow are drawn to separate destination,
+		// that is clipped and drawn over node when ascending the stack
+		if n.Props.IsOverflowHidden {
+			state.clipDst = state.dst
+			state.clipPos = nodePos
+			state.dst = utils.NewRGBAImageFromPool(int(math.Ceil(n.Size.W)), int(math.Ceil(n.Size.H)))
+			state.pos = utils.Pos{
+				Left: n.Props.Padding.Left(),
+				Top:  n.Props.Padding.Top(),
+			}
+		}
+
+		// Children of text node are its words, their effects are drawn at once before words themselves
+		if i > 0 && nodes[i-1].Level == n.Level+1 && nodes[i-1].Text != "" {
+			if err := renderParagraphEffects(state.dst, nodes, i, state.pos); err != nil {
+				return nil, err
+			}
+		}
+
 		state.node = n
 		stack.Push(state)
 	}
 
-	popupStack(&stack, 1)
+	popupStack(&stack, 1, dc.cache)
+
+	if stack[0].clipDst != nil {
+		stack[0].dst = applyClip(stack[0], dc.cache)
+		stack[0].clipDst = nil
+	}
 
 	return stack[0].dst, nil
 }
 
+// applyClip draws clipped children of node over node itself, and returns destination of node
+func applyClip(state drawState, cache *Cache) *image.RGBA {
+	if state.clipDst == nil {
+		return state.dst
+	}
+
+	applyBorderRadius(cache, state.dst, state.node.Props.BorderRadius)
+
+	bounds := state.dst.Bounds().Add(image.Pt(int(state.clipPos.Left), int(state.clipPos.Top)))
+	draw.Draw(state.clipDst, bounds, state.dst, image.Point{}, draw.Over)
+	utils.ReleaseImage(state.dst)
+
+	return state.clipDst
+}
+
 // At the next node, which is higher than the previous node in level,
 // it is necessary to ascend the stack as many times as needed.
 // Along the way, apply final renderings for rotations.
-func popupStack(stack *utils.Stack[drawState], level int) {
+func popupStack(stack *utils.Stack[drawState], level int, cache *Cache) {
 	if stack.Len() == 0 || level > stack.Last().node.Level {
 		return
 	}
@@ -124,6 +174,8 @@ func popupStack(stack *utils.Stack[drawState], level int) {
 		state := stack.Pop()
 		upperState := stack.Last()
 
+		state.dst = applyClip(state, cache)
+
 		if state.dst != upperState.dst && upperState.dst != nil {
 			// at this moment only case when destination may differ is rotation
 			// so perform rotation of image and then render it on image upper on stack
@@ -175,6 +227,11 @@ func drawNode(dst *image.RGBA, n *layout.Node, left float64, top float64, dc dra
 		if err := renderText(dst, n, left, top); err != nil {
 			return err
 		}
+		if n.Props.TextDecoration.Lines != 0 {
+			if err := renderTextDecoration(dst, n, left, top); err != nil {
+				return err
+			}
+		}
 	}
 
 	if n.Props.Border.Width > 0 {
@@ -184,37 +241,40 @@ func drawNode(dst *image.RGBA, n *layout.Node, left float64, top float64, dc dra
 	return nil
 }
 
-func renderText(dst draw.Image, n *layout.Node, left float64, top float64) error {
+// renderTextDecoration draws lines of text decoration at positions suggested by font
+func renderTextDecoration(dst draw.Image, n *layout.Node, left float64, top float64) error {
 	face, err := fonts.GetFontFace(n.Props.FontDescription)
 	if err != nil {
-		return fmt.Errorf("cant draw node text (id: %v): %w", n.Id, err)
+		return fmt.Errorf("cant draw node text decoration (id: %v): %w", n.Id, err)
 	}
 
-	offset := fonts.GetFontFaceBaseLineOffset(face, n.Size.H)
-	pt := fixed.P(int(left), int(top+offset))
-	ptY := pt.Y
-
-	colorUniform := image.Uniform{C: n.Props.FontColor}
-
-	for _, r := range n.Text {
-		r = utils.SimplifyRune(r)
+	decoration := n.Props.TextDecoration
+	position, thickness := fonts.GetUnderlineMetrics(n.Props.FontDescription)
+	if decoration.Thickness > 0 {
+		thickness = decoration.Thickness
+	}
+	c := decoration.Color
+	if c.A == 0 {
+		c = n.Props.FontColor
+	}
 
-		// Since there is no sophisticated font rasterizer as harfbuzz
-		// we have some issues with rendering some runes, like colons
-		if r == ':' || r == ';' {
-			pt.Y = ptY - fixed.I(int(3.0*n.Props.FontDescription.Size/44))
-		} else {
-			pt.Y = ptY
-		}
+	baseline := float64(int(top + fonts.GetFontFaceBaseLineOffset(face, n.Size.H)))
+	metrics := face.Metrics()
+	height := math.Max(1, math.Round(thickness))
 
-		// Better to skip unknown symbol
-		dr, mask, maskPoint, advance, ok := face.Glyph(pt, r)
-		if !ok {
-			continue
-		}
+	drawLine := func(y float64) {
+		rect := image.Rect(int(left), int(math.Round(y)), int(left+n.TextDecorationWidth), int(math.Round(y)+height))
+		draw.Draw(dst, rect, &image.Uniform{C: alphaPremultiply(c)}, image.Point{}, draw.Over)
+	}
 
-		draw.DrawMask(dst, dr.Bounds(), &colorUniform, image.Point{}, mask, maskPoint, draw.Over)
-		pt.X += advance
+	if decoration.Has(utils.TextDecorationUnderline) {
+		drawLine(baseline + position)
+	}
+	if decoration.Has(utils.TextDecorationLineThrough) {
+		drawLine(baseline - float64(metrics.XHeight)/64/2 - height/2)
+	}
+	if decoration.Has(utils.TextDecorationOverline) {
+		drawLine(baseline - float64(metrics.Ascent)/64)
 	}
 
 	return nil
@@ -0,0 +1,267 @@
+package render
+
+import (
+	"fmt"
+	"github.com/godknowsiamgood/decorender/internal/fonts"
+	"github.com/godknowsiamgood/decorender/internal/layout"
+	"github.com/godknowsiamgood/decorender/internal/utils"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+	"image"
+	"image/color"
+	"math"
+	"sync"
+)
+
+var glyphsBufferPool = sync.Pool{
+	New: func() any {
+		return &sfnt.Buffer{}
+	},
+}
+
+// renderText draws shaped glyphs of text, that are rasterized from their outlines
+func renderText(dst draw.Image, n *layout.Node, left float64, top float64) error {
+	mask, origin, err := rasterizeText(n, left, top, 0)
+	if err != nil {
+		return fmt.Errorf("cant draw node text (id: %v): %w", n.Id, err)
+	}
+
+	drawColorMask(dst, mask, origin, n.Props.FontColor)
+	utils.ReleaseImage(mask)
+
+	return nil
+}
+
+// renderParagraphEffects draws shadows and then strokes of all words of paragraph, that are children
+// of node at index. They are drawn before glyphs of any word, so effects of word are never drawn
+// over glyphs of previous words. Both are made of the same mask as text, which is widened for stroke
+// and blurred for shadow.
+func renderParagraphEffects(dst draw.Image, nodes layout.Nodes, index int, pos utils.Pos) error {
+	level := nodes[index].Level + 1
+
+	for _, isShadow := range []bool{true, false} {
+		for i := index - 1; i >= 0 && nodes[i].Level >= level; i-- {
+			n := &nodes[i]
+			if n.Level != level || n.Text == "" {
+				continue
+			}
+			if err := renderTextEffect(dst, n, pos.Left+n.Pos.Left, pos.Top+n.Pos.Top, isShadow); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// renderTextEffect draws either shadow or stroke of text
+func renderTextEffect(dst draw.Image, n *layout.Node, left float64, top float64, isShadow bool) error {
+	stroke, shadow := n.Props.TextStroke, n.Props.TextShadow
+	if isShadow && shadow == (utils.TextShadow{}) || !isShadow && stroke.Width <= 0 {
+		return nil
+	}
+
+	// Blur radius is treated as doubled standard deviation, and mask is enlarged,
+	// so neither stroke nor blurred shadow are cut at bounds of node
+	sigma := shadow.Blur / 2
+	mask, origin, err := rasterizeText(n, left, top, int(math.Ceil(stroke.Width+sigma*3))+2)
+	if err != nil {
+		return fmt.Errorf("cant draw node text effects (id: %v): %w", n.Id, err)
+	}
+
+	if stroke.Width > 0 {
+		mask = dilateMask(mask, stroke.Width)
+	}
+
+	if !isShadow {
+		drawColorMask(dst, mask, origin, getTextEffectColor(stroke.Color, n))
+		utils.ReleaseImage(mask)
+		return nil
+	}
+
+	if sigma > 0 {
+		mask = blurMask(mask, sigma)
+	}
+	offset := image.Pt(int(math.Round(shadow.OffsetX)), int(math.Round(shadow.OffsetY)))
+	drawColorMask(dst, mask, origin.Add(offset), getTextEffectColor(shadow.Color, n))
+	utils.ReleaseImage(mask)
+
+	return nil
+}
+
+// rasterizeText returns mask of shaped text glyphs and position of mask in destination.
+// Mask covers node with margin, where glyphs may stick out of node, and extra margin.
+// Mask is taken from pool and should be released after drawing.
+func rasterizeText(n *layout.Node, left float64, top float64, extraMargin int) (*image.Alpha, image.Point, error) {
+	fd := n.Props.FontDescription
+	f, err := fonts.GetFont(fd)
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+	face, err := fonts.GetFontFace(fd)
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+	glyphs, _, err := fonts.ShapeText(n.Text, fd)
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+
+	// Glyphs stick out of node when line height is less than height of font, or with overhanging glyphs
+	metrics := face.Metrics()
+	overflow := math.Max(0, float64(metrics.Ascent.Ceil()+metrics.Descent.Ceil())-n.Size.H) / 2
+	margin := int(math.Ceil(overflow+fd.Size/4)) + extraMargin
+
+	origin := image.Pt(int(left)-margin, int(top)-margin)
+	width, height := int(math.Ceil(n.Size.W))+margin*2, int(math.Ceil(n.Size.H))+margin*2
+
+	r := rasterizerPool.Get().(*vector.Rasterizer)
+	defer rasterizerPool.Put(r)
+	r.Reset(width, height)
+
+	buf := glyphsBufferPool.Get().(*sfnt.Buffer)
+	defer glyphsBufferPool.Put(buf)
+	ppem := fixed.Int26_6(fd.Size * 64)
+	x := float64(int(left) - origin.X)
+	baseline := float64(int(top+fonts.GetFontFaceBaseLineOffset(face, n.Size.H)) - origin.Y)
+
+	for _, g := range glyphs {
+		// Better to skip unknown symbol
+		if g.Index == 0 {
+			continue
+		}
+		segments, err := f.LoadGlyph(buf, g.Index, ppem, nil)
+		if err != nil {
+			continue
+		}
+		addGlyphSegments(r, segments, float32(x+g.X), float32(baseline+g.Y))
+	}
+
+	mask := utils.NewAlphaImageFromPool(width, height)
+	r.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+
+	return mask, origin, nil
+}
+
+// addGlyphSegments adds outline of glyph with origin at x and y to rasterizer
+func addGlyphSegments(r *vector.Rasterizer, segments sfnt.Segments, x float32, y float32) {
+	point := func(p fixed.Point26_6) (float32, float32) {
+		return x + float32(p.X)/64, y + float32(p.Y)/64
+	}
+
+	for _, s := range segments {
+		switch s.Op {
+		case sfnt.SegmentOpMoveTo:
+			r.MoveTo(point(s.Args[0]))
+		case sfnt.SegmentOpLineTo:
+			r.LineTo(point(s.Args[0]))
+		case sfnt.SegmentOpQuadTo:
+			x1, y1 := point(s.Args[0])
+			x2, y2 := point(s.Args[1])
+			r.QuadTo(x1, y1, x2, y2)
+		case sfnt.SegmentOpCubeTo:
+			x1, y1 := point(s.Args[0])
+			x2, y2 := point(s.Args[1])
+			x3, y3 := point(s.Args[2])
+			r.CubeTo(x1, y1, x2, y2, x3, y3)
+		}
+	}
+}
+
+// dilateMask widens mask by radius in all directions, edge of widened mask is antialiased.
+// Given mask is released, and widened one is taken from pool.
+func dilateMask(mask *image.Alpha, radius float64) *image.Alpha {
+	type weightedOffset struct {
+		dx, dy int
+		weight float64
+	}
+
+	var offsets []weightedOffset
+	reach := int(math.Ceil(radius))
+	for dy := -reach; dy <= reach; dy++ {
+		for dx := -reach; dx <= reach; dx++ {
+			if weight := math.Min(1, radius+0.5-math.Hypot(float64(dx), float64(dy))); weight > 0 {
+				offsets = append(offsets, weightedOffset{dx: dx, dy: dy, weight: weight})
+			}
+		}
+	}
+
+	bounds := mask.Bounds()
+	res := utils.NewAlphaImageFromPool(bounds.Dx(), bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			a := mask.Pix[mask.PixOffset(x, y)]
+			if a == 0 {
+				continue
+			}
+			for _, o := range offsets {
+				p := image.Pt(x+o.dx, y+o.dy)
+				if !p.In(bounds) {
+					continue
+				}
+				i := res.PixOffset(p.X, p.Y)
+				res.Pix[i] = uint8(math.Max(float64(res.Pix[i]), float64(a)*o.weight))
+			}
+		}
+	}
+
+	utils.ReleaseImage(mask)
+
+	return res
+}
+
+// blurMask applies gaussian blur with standard deviation sigma to mask, in the same way as
+// imaging.Blur does, but in two pooled masks instead of new images for every pass.
+// Given mask is released, and blurred one is taken from pool.
+func blurMask(mask *image.Alpha, sigma float64) *image.Alpha {
+	radius := int(math.Ceil(sigma * 3))
+	kernel := make([]float64, radius+1)
+	for i := range kernel {
+		kernel[i] = math.Exp(-float64(i*i)/(2*sigma*sigma)) / (sigma * math.Sqrt(2*math.Pi))
+	}
+
+	w, h := mask.Bounds().Dx(), mask.Bounds().Dy()
+	blurLine := func(dst []uint8, src []uint8, length int, step int) {
+		for i := 0; i < length; i++ {
+			var sum, wsum float64
+			for j := i - radius; j <= i+radius; j++ {
+				if j < 0 || j >= length {
+					continue
+				}
+				weight := kernel[int(math.Abs(float64(i-j)))]
+				sum += float64(src[j*step]) * weight
+				wsum += weight
+			}
+			dst[i*step] = uint8(math.Min(255, sum/wsum+0.5))
+		}
+	}
+
+	horizontal := utils.NewAlphaImageFromPool(w, h)
+	for y := 0; y < h; y++ {
+		blurLine(horizontal.Pix[y*horizontal.Stride:], mask.Pix[y*mask.Stride:], w, 1)
+	}
+	utils.ReleaseImage(mask)
+
+	res := utils.NewAlphaImageFromPool(w, h)
+	for x := 0; x < w; x++ {
+		blurLine(res.Pix[x:], horizontal.Pix[x:], h, res.Stride)
+	}
+	utils.ReleaseImage(horizontal)
+
+	return res
+}
+
+func drawColorMask(dst draw.Image, mask image.Image, pt image.Point, c color.RGBA) {
+	bounds := mask.Bounds().Sub(mask.Bounds().Min).Add(pt)
+	draw.DrawMask(dst, bounds, &image.Uniform{C: alphaPremultiply(c)}, image.Point{}, mask, mask.Bounds().Min, draw.Over)
+}
+
+// getTextEffectColor returns color of stroke or shadow, that is color of text if not set
+func getTextEffectColor(c color.RGBA, n *layout.Node) color.RGBA {
+	if c.A == 0 {
+		return n.Props.FontColor
+	}
+	return c
+}
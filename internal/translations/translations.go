@@ -0,0 +1,142 @@
+// Package translations contains catalogs of translated texts for template expressions
+package translations
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"gopkg.in/yaml.v3"
+)
+
+// Catalogs are translated texts by language and key
+type Catalogs struct {
+	catalogs  map[string]map[string]string
+	languages []string
+	matcher   language.Matcher
+}
+
+// Load reads catalogs from files by language, e.g. {"en": "i18n/en.yaml"}.
+// Files are YAML or JSON objects, nested objects are flattened to keys with dots, e.g. "ticket.title".
+func Load(files map[string]string, fsys fs.FS) (*Catalogs, error) {
+	c := &Catalogs{
+		catalogs: make(map[string]map[string]string, len(files)),
+	}
+
+	for lang, fileName := range files {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			return nil, fmt.Errorf("translations: invalid language \"%v\"", lang)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Clean(fileName))
+		if err != nil {
+			return nil, fmt.Errorf("translations: can't read %v: %w", fileName, err)
+		}
+
+		var data map[string]any
+		if err = yaml.Unmarshal(content, &data); err != nil {
+			return nil, fmt.Errorf("%v: %w", fileName, err)
+		}
+
+		catalog := make(map[string]string)
+		if err = flatten(data, "", catalog); err != nil {
+			return nil, fmt.Errorf("%v: %w", fileName, err)
+		}
+
+		c.catalogs[tag.String()] = catalog
+		c.languages = append(c.languages, tag.String())
+	}
+
+	sort.Strings(c.languages)
+
+	tags := make([]language.Tag, len(c.languages))
+	for i, lang := range c.languages {
+		tags[i] = language.Make(lang)
+	}
+	c.matcher = language.NewMatcher(tags)
+
+	return c, nil
+}
+
+func flatten(data map[string]any, prefix string, dst map[string]string) error {
+	for key, value := range data {
+		switch v := value.(type) {
+		case map[string]any:
+			if err := flatten(v, prefix+key+".", dst); err != nil {
+				return err
+			}
+		case string:
+			dst[prefix+key] = v
+		case int, float64, bool:
+			dst[prefix+key] = fmt.Sprintf("%v", v)
+		default:
+			return fmt.Errorf("value of key %v%v must be a string", prefix, key)
+		}
+	}
+	return nil
+}
+
+// Match returns language of catalog that is the closest to requested one, e.g. "de" for "de-AT".
+// Result is empty if there are no catalogs or no suitable one.
+func (c *Catalogs) Match(lang string) string {
+	if c == nil || len(c.languages) == 0 || lang == "" {
+		return ""
+	}
+
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return ""
+	}
+
+	_, index, confidence := c.matcher.Match(tag)
+	if confidence < language.High {
+		return ""
+	}
+
+	return c.languages[index]
+}
+
+// Translate returns text of key in catalog of language, or in catalog of fallback language if key is missing there.
+// If key is missing everywhere, key itself is returned.
+// Args are formatted into text with fmt verbs, numbers are formatted according to locale.
+func (c *Catalogs) Translate(lang string, fallbackLang string, locale language.Tag, key string, args ...any) string {
+	text, has := c.lookup(lang, key)
+	if !has {
+		if text, has = c.lookup(fallbackLang, key); !has {
+			text = key
+		}
+	}
+
+	if len(args) == 0 {
+		return text
+	}
+
+	return message.NewPrinter(locale).Sprintf(text, args...)
+}
+
+func (c *Catalogs) lookup(lang string, key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	text, has := c.catalogs[lang][key]
+	return text, has
+}
+
+// MissingLanguages returns languages whose catalogs have no key
+func (c *Catalogs) MissingLanguages(key string) []string {
+	if c == nil {
+		return nil
+	}
+
+	var missing []string
+	for _, lang := range c.languages {
+		if _, has := c.catalogs[lang][key]; !has {
+			missing = append(missing, lang)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,40 @@
+package translations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestCatalogs(t *testing.T) {
+	files := fstest.MapFS{
+		"i18n/en.yaml": {Data: []byte(`
+title: Ticket
+seats: "%d seats"
+ticket:
+  price: Price
+`)},
+		"i18n/de.json": {Data: []byte(`{"title": "Fahrkarte", "seats": "%d Plätze"}`)},
+	}
+
+	c, err := Load(map[string]string{"en": "i18n/en.yaml", "de": "./i18n/de.json"}, files)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "de", c.Match("de-AT"))
+	assert.Equal(t, "en", c.Match("en-GB"))
+	assert.Equal(t, "", c.Match("ja"))
+
+	de := language.German
+	assert.Equal(t, "Fahrkarte", c.Translate("de", "en", de, "title"))
+	assert.Equal(t, "1.200 Plätze", c.Translate("de", "en", de, "seats", 1200))
+	assert.Equal(t, "Price", c.Translate("de", "en", de, "ticket.price"))
+	assert.Equal(t, "unknown", c.Translate("de", "en", de, "unknown"))
+
+	assert.Equal(t, []string{"de"}, c.MissingLanguages("ticket.price"))
+	assert.Empty(t, c.MissingLanguages("title"))
+
+	_, err = Load(map[string]string{"en": "i18n/missing.yaml"}, files)
+	assert.Error(t, err)
+}
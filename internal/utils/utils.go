@@ -105,6 +105,40 @@ func (b *Border) GetOutsetOffset() float64 {
 	}
 }
 
+type TextDecorationLine int
+
+const (
+	TextDecorationUnderline TextDecorationLine = 1 << iota
+	TextDecorationLineThrough
+	TextDecorationOverline
+)
+
+// TextDecoration is lines drawn along text. Color with zero alpha means color of text,
+// and zero thickness means thickness suggested by font.
+type TextDecoration struct {
+	Lines     TextDecorationLine
+	Color     color.RGBA
+	Thickness float64
+}
+
+func (d TextDecoration) Has(line TextDecorationLine) bool {
+	return d.Lines&line != 0
+}
+
+// TextStroke is outline of given width around glyphs of text. Color with zero alpha means color of text.
+type TextStroke struct {
+	Width float64
+	Color color.RGBA
+}
+
+// TextShadow is blurred copy of text drawn under it with offset. Color with zero alpha means color of text.
+type TextShadow struct {
+	OffsetX float64
+	OffsetY float64
+	Blur    float64
+	Color   color.RGBA
+}
+
 func GetSha256(str string) string {
 	hash := sha256.Sum256([]byte(str))
 	return hex.EncodeToString(hash[:])